@@ -0,0 +1,109 @@
+// This file implements the canvas of the render package on the screen, so that
+// the same code draws the network on the screen and exports it as an image: a
+// PNG file, rendered exactly like the window, or an SVG file.
+
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"network-manager/render"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// draws on an ebiten image, the screen or an offscreen one
+type screenCanvas struct {
+	img *ebiten.Image
+}
+
+func (s screenCanvas) StrokeLine(x0, y0, x1, y1, width float32, c color.Color) {
+	vector.StrokeLine(s.img, x0, y0, x1, y1, width, c, true)
+}
+
+func (s screenCanvas) FillTriangle(x0, y0, x1, y1, x2, y2 float32, c color.Color) {
+	cr, cg, cb, _ := c.RGBA()
+	rf32 := float32(cr) / 0xffff
+	gf32 := float32(cg) / 0xffff
+	bf32 := float32(cb) / 0xffff
+
+	vertices := []ebiten.Vertex{
+		{DstX: x0, DstY: y0, ColorR: rf32, ColorG: gf32, ColorB: bf32, ColorA: 1.0},
+		{DstX: x1, DstY: y1, ColorR: rf32, ColorG: gf32, ColorB: bf32, ColorA: 1.0},
+		{DstX: x2, DstY: y2, ColorR: rf32, ColorG: gf32, ColorB: bf32, ColorA: 1.0},
+	}
+
+	s.img.DrawTriangles(vertices, []uint16{0, 1, 2}, blackImage, nil)
+}
+
+func (s screenCanvas) FillRect(x, y, w, h float32, c color.Color) {
+	vector.DrawFilledRect(s.img, x, y, w, h, c, false)
+}
+
+func (s screenCanvas) StrokeRect(x, y, w, h, width float32, c color.Color) {
+	vector.StrokeRect(s.img, x, y, w, h, width, c, true)
+}
+
+func (s screenCanvas) FillCircle(x, y, r float32, c color.Color) {
+	vector.DrawFilledCircle(s.img, x, y, r, c, true)
+}
+
+func (s screenCanvas) Text(str string, x, y float32, c color.Color) {
+	op := &text.DrawOptions{}
+	op.GeoM.Translate(float64(x), float64(y))
+	op.ColorScale.ScaleWithColor(c)
+	op.SecondaryAlign = text.AlignCenter
+
+	text.Draw(s.img, str, labelFace, op)
+}
+
+// writes the network as it is shown in the window, without the UI, as an SVG
+// image of the same size
+func (g *Game) writeSVG(w io.Writer) error {
+	return render.WriteSVG(w, g.screenW, g.screenH, g.drawNetwork)
+}
+
+// Writes the network as it is shown in the window, without the UI, as a PNG
+// image of the same size. Since it is rendered by ebiten, it can only be
+// called while the game is running.
+func (g *Game) writePNG(w io.Writer) error {
+	img := ebiten.NewImage(g.screenW, g.screenH)
+	defer img.Deallocate()
+
+	img.Fill(color.White)
+	g.drawNetwork(screenCanvas{img})
+
+	rgba := image.NewRGBA(image.Rect(0, 0, g.screenW, g.screenH))
+	img.ReadPixels(rgba.Pix)
+
+	return png.Encode(w, rgba)
+}
+
+// saves the canvas to the file at path, as an SVG image if its extension is
+// .svg, as a PNG one otherwise
+func (g *Game) exportImage(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if strings.ToLower(filepath.Ext(path)) == ".svg" {
+		err = g.writeSVG(f)
+	} else {
+		err = g.writePNG(f)
+	}
+
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
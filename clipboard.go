@@ -0,0 +1,145 @@
+// This file implements copy, paste and duplication of groups of nodes. The
+// clipboard holds the copied nodes and the channels between them in the same
+// text format used to save networks, and it is also stored in a file, so that
+// nodes can be pasted in another session of the program.
+
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"network-manager/engine"
+)
+
+// distance between the duplicated nodes and the original ones
+const DUPLICATE_OFFSET = 40
+
+// contents of the clipboard in this session, empty until something is copied
+var clipboard string
+
+// returns the path of the file which stores the clipboard between sessions
+func clipboardPath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "network-manager", "clipboard.dot"), nil
+}
+
+// serializes the selected nodes and the channels between them
+func (g *Game) serializeSelection() string {
+	var b strings.Builder
+	g.net.SerializeNodes(&b, engine.DOT, g.selectedNodes())
+
+	return b.String()
+}
+
+// copies the selected nodes to the clipboard
+func (g *Game) copySelection() {
+	if len(g.selectedNodes()) == 0 {
+		return
+	}
+
+	clipboard = g.serializeSelection()
+
+	p, err := clipboardPath()
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(p), 0755)
+	}
+
+	if err == nil {
+		err = os.WriteFile(p, []byte(clipboard), 0644)
+	}
+
+	if err != nil {
+		log.Printf("[manager] couldn't write the clipboard file: %v", err)
+	}
+}
+
+// returns the contents of the clipboard; if nothing was copied in this session,
+// those of the clipboard file, so that nodes copied in another session can be
+// pasted
+func readClipboard() string {
+	if clipboard != "" {
+		return clipboard
+	}
+
+	p, err := clipboardPath()
+	if err != nil {
+		return ""
+	}
+
+	bs, err := os.ReadFile(p)
+	if err != nil {
+		return ""
+	}
+
+	return string(bs)
+}
+
+// Adds the nodes in text to the network, with fresh IDs and centered on (x, y),
+// and selects them. Pasting can be undone like any other edit.
+func (g *Game) pasteText(text string, x, y int) {
+	g.mergeNodes(strings.NewReader(text), engine.DOT, x, y, false)
+}
+
+// Adds the nodes read from r, in the format of the codec c, to the network,
+// centered on (x, y), and selects them; the running nodes are left alone. The
+// new nodes get fresh IDs, or keep those which are free if keepIDs is set
+// (see Network.Import). Used to paste and to import files.
+func (g *Game) mergeNodes(r io.Reader, c engine.Codec, x, y int, keepIDs bool) {
+	var ids []engine.NodeID
+	var err error
+
+	g.edit(func() {
+		if keepIDs {
+			ids, err = g.net.Import(r, c, x, y)
+		} else {
+			ids, err = g.net.Merge(r, c, x, y)
+		}
+	})
+
+	if err != nil {
+		parseErrPopUp(g, err)
+		return
+	}
+
+	clear(g.selection)
+	for _, id := range ids {
+		g.selection[id] = true
+	}
+}
+
+// pastes the clipboard at the mouse pointer
+func (g *Game) paste() {
+	if text := readClipboard(); text != "" {
+		g.pasteText(text, g.wmx, g.wmy)
+	}
+}
+
+// copies the selected nodes next to the original ones, without changing the
+// clipboard
+func (g *Game) duplicateSelection() {
+	ids := g.selectedNodes()
+	if len(ids) == 0 {
+		return
+	}
+
+	// center of the selection, which Merge uses as reference point
+	n, _ := g.net.Node(ids[0])
+	x0, y0, x1, y1 := n.X, n.Y, n.X, n.Y
+
+	for _, id := range ids {
+		n, _ := g.net.Node(id)
+		x0, y0 = min(x0, n.X), min(y0, n.Y)
+		x1, y1 = max(x1, n.X), max(y1, n.Y)
+	}
+
+	g.pasteText(g.serializeSelection(),
+		(x0+x1)/2+DUPLICATE_OFFSET, (y0+y1)/2+DUPLICATE_OFFSET)
+}
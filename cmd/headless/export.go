@@ -0,0 +1,106 @@
+// This file implements the `export` subcommand, which converts a saved network
+// to another format without running it, e.g. to GraphML or Mermaid for other
+// tools, or draws it as an SVG image.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"network-manager/engine"
+	"network-manager/render"
+)
+
+// Parses the arguments of the export subcommand and converts the input file.
+// The formats are chosen by the extensions of the files, unless -format is
+// given; "-" as output writes to standard output.
+func exportHeadless(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+
+	var names []string
+	for name := range engine.CODECS {
+		names = append(names, name)
+	}
+	// images are not codecs, they are drawn like the canvas
+	names = append(names, "svg")
+	slices.Sort(names)
+
+	format := fs.String("format", "",
+		"format of the output: "+strings.Join(names, ", ")+
+			" (default: from the extension of the output file)")
+
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(),
+			"usage: headless export [-format f] <input file> <output file>")
+		fs.PrintDefaults()
+	}
+
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errors.New("expected an input and an output file")
+	}
+
+	in, out := fs.Arg(0), fs.Arg(1)
+
+	svg := *format == "svg" ||
+		*format == "" && strings.ToLower(filepath.Ext(out)) == ".svg"
+
+	to := engine.CodecFor(out)
+	if *format != "" && !svg {
+		var ok bool
+		if to, ok = engine.CODECS[*format]; !ok {
+			fs.Usage()
+			return fmt.Errorf("unknown format %q", *format)
+		}
+	}
+
+	f, err := os.Open(in)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// the output file is created only if the input can be read, so that a
+	// file with errors does not leave an empty output
+	var b strings.Builder
+
+	if svg {
+		// the image is drawn from the nodes read from the file, which
+		// are not spawned
+		nodes, err := engine.ReadNodes(f, engine.CodecFor(in))
+		if err != nil {
+			return fmt.Errorf("error during parsing:\n%v", err)
+		}
+
+		if err := render.WriteNetworkSVG(&b, nodes); err != nil {
+			return err
+		}
+	} else if err := engine.Convert(&b, to, f, engine.CodecFor(in)); err != nil {
+		return fmt.Errorf("error during parsing:\n%v", err)
+	}
+
+	var w io.Writer = os.Stdout
+
+	if out != "-" {
+		o, err := os.Create(out)
+		if err != nil {
+			return err
+		}
+		defer o.Close()
+
+		w = o
+	}
+
+	_, err = io.WriteString(w, b.String())
+
+	return err
+}
@@ -0,0 +1,32 @@
+// Command headless works with saved networks without opening a window, e.g. on
+// machines without a display or in CI: it only depends on the engine and render
+// packages, and not on the graphical interface and its libraries.
+//
+// Usage:
+//
+//	headless run [-duration d] [-messages n] [-stall-timeout d] <file>
+//	headless export [-format f] <input file> <output file>
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+)
+
+// the subcommands, by name
+var commands = map[string]func(args []string) error{
+	"run":    runHeadless,
+	"export": exportHeadless,
+}
+
+func main() {
+	if len(os.Args) < 2 || commands[os.Args[1]] == nil {
+		fmt.Fprintln(os.Stderr, "usage: headless (run | export) [options] <file>...")
+		os.Exit(2)
+	}
+
+	if err := commands[os.Args[1]](os.Args[2:]); err != nil {
+		log.Fatal(err)
+	}
+}
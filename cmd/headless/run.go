@@ -0,0 +1,139 @@
+// This file implements the `run` subcommand, which loads a network from a file
+// and runs it without opening a window, e.g. on machines without a display.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"network-manager/engine"
+)
+
+// how often the headless runner polls the network, same as the UI update rate
+const RUN_POLL_INTERVAL = time.Second / 60
+
+// Parses the arguments of the run subcommand, runs the network and prints a
+// summary of the messages sent and received by each node to standard output.
+// The log of the nodes is written to standard error as usual.
+func runHeadless(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+
+	duration := fs.Duration("duration", 0,
+		"stop after the given time (e.g. 10s)")
+	count := fs.Int("messages", 0,
+		"stop after the given number of messages has been sent")
+	stallTimeout := fs.Duration("stall-timeout", engine.DEFAULT_STALL_TIMEOUT,
+		"report nodes blocked for longer than this (0 to disable)")
+
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(),
+			"usage: headless run [-duration d] [-messages n] [-stall-timeout d] <file>")
+		fs.PrintDefaults()
+	}
+
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("expected exactly one file")
+	}
+
+	if *duration <= 0 && *count <= 0 {
+		fs.Usage()
+		return errors.New("at least one of -duration and -messages is required")
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	net := engine.New()
+	net.SetStallTimeout(*stallTimeout)
+
+	err = net.Deserialize(f, engine.CodecFor(fs.Arg(0)))
+	f.Close()
+
+	if err != nil {
+		return fmt.Errorf("error during parsing:\n%v", err)
+	}
+
+	// count the messages sent by each node; the received ones are counted
+	// by the nodes themselves, since the links can delay, lose or evict the
+	// messages which were sent
+	sent := make(map[engine.NodeID]int)
+	total := 0
+
+	net.Subscribe(func(r engine.SendReport) {
+		sent[r.Src]++
+		total++
+	})
+
+	// a nil channel never fires, so without -duration we only stop on the
+	// message count
+	var timeout <-chan time.Time
+	if *duration > 0 {
+		timeout = time.After(*duration)
+	}
+
+	ticker := time.NewTicker(RUN_POLL_INTERVAL)
+	defer ticker.Stop()
+
+loop:
+	for {
+		select {
+		case <-timeout:
+			break loop
+
+		case <-ticker.C:
+			net.Poll()
+
+			if *count > 0 && total >= *count {
+				break loop
+			}
+		}
+	}
+
+	printSummary(net, sent)
+
+	net.StopAllAndWait()
+
+	return nil
+}
+
+// prints a table with the messages sent and received by each node, those it
+// dropped because of the TTL or because an output channel was full, and those
+// lost by its output channels, sorted by ID
+func printSummary(net *engine.Network, sent map[engine.NodeID]int) {
+	ids := make([]engine.NodeID, 0, len(net.Nodes()))
+	for id := range net.Nodes() {
+		ids = append(ids, id)
+	}
+
+	slices.Sort(ids)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintln(w, "ID\tNAME\tSENT\tRECEIVED\tEXPIRED\tDROPPED\tLOST")
+
+	for _, id := range ids {
+		n, _ := net.Node(id)
+
+		dropped, lost := 0, 0
+		for _, o := range n.Outs {
+			dropped += o.Dropped
+			lost += o.Lost
+		}
+
+		fmt.Fprintf(w, "%d\t%s\t%d\t%d\t%d\t%d\t%d\n",
+			id, n.Name, sent[id], n.Received(), n.Expired, dropped, lost)
+	}
+
+	w.Flush()
+}
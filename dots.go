@@ -0,0 +1,119 @@
+// This file implements the animation of the messages flowing through the
+// network: every send spawns a dot which travels along the channel, from the
+// source to the destination, colored by the node where the message originated.
+
+package main
+
+import (
+	"image/color"
+	"time"
+
+	"network-manager/engine"
+	"network-manager/render"
+)
+
+// default time taken by a dot to go from the source to the destination
+const DEFAULT_TRAVEL_TIME = 500 * time.Millisecond
+
+// When many messages are sent, only some of them get a dot: if in the last
+// update more than MAX_DOTS_PER_UPDATE were sent, the following sends are
+// sampled so that about MAX_DOTS_PER_UPDATE dots are spawned on each update.
+// On top of that, there are never more than MAX_DOTS dots at the same time.
+const MAX_DOTS_PER_UPDATE = 20
+const MAX_DOTS = 1000
+
+// radius of the dots at zoom 1
+const DOT_RADIUS = 3
+
+// colors of the dots; the color of a message is chosen by its origin
+var DOT_COLORS = []color.Color{
+	color.RGBA{0x1f, 0x77, 0xb4, 0xff}, // blue
+	color.RGBA{0xff, 0x7f, 0x0e, 0xff}, // orange
+	color.RGBA{0x2c, 0xa0, 0x2c, 0xff}, // green
+	color.RGBA{0x94, 0x67, 0xbd, 0xff}, // purple
+	color.RGBA{0x8c, 0x56, 0x4b, 0xff}, // brown
+	color.RGBA{0xe3, 0x77, 0xc2, 0xff}, // pink
+	color.RGBA{0xbc, 0xbd, 0x22, 0xff}, // olive
+	color.RGBA{0x17, 0xbe, 0xcf, 0xff}, // cyan
+}
+
+// a message travelling on a channel
+type dot struct {
+	e      edge
+	origin engine.NodeID
+	start  time.Time
+}
+
+// the dots on the screen and the state of the sampling
+type flow struct {
+	dots []dot
+
+	// time taken by each dot to go through its channel; 0 disables the
+	// animation
+	travelTime time.Duration
+
+	reports int // sends reported since the last update
+	stride  int // a dot is spawned every stride reports
+}
+
+// called for every send reported by the network
+func (f *flow) report(r engine.SendReport) {
+	f.reports++
+
+	if f.travelTime <= 0 || len(f.dots) >= MAX_DOTS {
+		return
+	}
+
+	if (f.reports-1)%f.stride != 0 {
+		return
+	}
+
+	f.dots = append(f.dots, dot{edge{r.Src, r.Dst}, r.Msg.Origin, time.Now()})
+}
+
+// Called on every update, before polling the network. Removes the dots which
+// have arrived and computes the sampling stride for the next update from the
+// number of sends in the last one.
+func (f *flow) update(now time.Time) {
+	i := 0
+	for _, d := range f.dots {
+		if now.Sub(d.start) < f.travelTime {
+			f.dots[i] = d
+			i++
+		}
+	}
+
+	f.dots = f.dots[:i]
+
+	f.stride = max(1, (f.reports+MAX_DOTS_PER_UPDATE-1)/MAX_DOTS_PER_UPDATE)
+	f.reports = 0
+}
+
+// draws the dots on their channels, skipping those whose endpoints have been
+// stopped in the meantime
+func (g *Game) drawDots(cv render.Canvas) {
+	now := time.Now()
+	r := float32(max(1, DOT_RADIUS*g.zoom))
+
+	for _, d := range g.flow.dots {
+		if _, ok := g.net.Node(d.e.src); !ok {
+			continue
+		}
+
+		if _, ok := g.net.Node(d.e.dst); !ok {
+			continue
+		}
+
+		t := min(1, float64(now.Sub(d.start))/float64(g.flow.travelTime))
+
+		x0, y0 := g.nodeScreenPos(d.e.src)
+		x1, y1 := g.nodeScreenPos(d.e.dst)
+
+		x := float64(x0) + t*float64(x1-x0)
+		y := float64(y0) + t*float64(y1-y0)
+
+		c := DOT_COLORS[int(d.origin)%len(DOT_COLORS)]
+
+		cv.FillCircle(float32(x), float32(y), r, c)
+	}
+}
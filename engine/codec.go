@@ -0,0 +1,110 @@
+package engine
+
+import (
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// A Codec is a file format in which networks can be saved and loaded. The
+// available codecs are DOT, JSON, GRAPHML and MERMAID (the last two can only
+// be written); CodecFor chooses one from the name of a file.
+//
+// Codecs do not deal with the running network: they write and read the
+// specifications of its nodes and channels, which the Network methods
+// (Serialize, Deserialize, Merge) take from and apply to the nodes.
+type Codec interface {
+	// writes the given nodes and channels; the source and destination of
+	// each channel are among the nodes
+	encode(w io.Writer, nodes []nodespec, chans []chanspec) error
+
+	// reads the nodes and channels stored by encode; errors in the contents
+	// of the file are returned as ParseErrors, formats which can only be
+	// written always return an error
+	decode(r io.Reader) ([]nodespec, []chanspec, error)
+}
+
+// the language of Graphviz, described in the README.org file
+var DOT Codec = dotCodec{}
+
+// a JSON object with the nodes and channels, described in the README.org file
+var JSON Codec = jsonCodec{}
+
+// GraphML, for graph analysis tools; write only
+var GRAPHML Codec = graphmlCodec{}
+
+// a Mermaid flowchart, for documentation; write only
+var MERMAID Codec = mermaidCodec{}
+
+// the codecs by name, e.g. for command line options
+var CODECS = map[string]Codec{
+	"dot":     DOT,
+	"json":    JSON,
+	"graphml": GRAPHML,
+	"mermaid": MERMAID,
+}
+
+// Returns the codec for the file with the given name, chosen by its extension:
+// JSON for .json files, GraphML for .graphml, Mermaid for .mmd and .mermaid,
+// DOT for all the others (usually .dot or .gv).
+func CodecFor(path string) Codec {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return JSON
+	case ".graphml":
+		return GRAPHML
+	case ".mmd", ".mermaid":
+		return MERMAID
+	default:
+		return DOT
+	}
+}
+
+// Reads a network in the format of the codec from and writes it in the format
+// of the codec to, without spawning its nodes. Returns the same errors as
+// Deserialize.
+func Convert(w io.Writer, to Codec, r io.Reader, from Codec) error {
+	nodes, chans, err := from.decode(r)
+	if err != nil {
+		return err
+	}
+
+	return to.encode(w, nodes, chans)
+}
+
+// Reads a network in the format of the codec and returns its nodes, in the
+// order of the file and with their output channels, without spawning them:
+// only their parameters are set, e.g. to draw the network. Returns the same
+// errors as Deserialize.
+func ReadNodes(r io.Reader, c Codec) ([]Node, error) {
+	specs, chans, err := c.decode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make([]Node, len(specs))
+	index := make(map[NodeID]int, len(specs))
+
+	for i, n := range specs {
+		nodes[i] = Node{
+			ID:           n.id,
+			Name:         n.name,
+			SendText:     n.sendText,
+			SendInterval: n.sendInterval,
+			RelayMode:    n.relayMode,
+			TTL:          n.ttl,
+			Paused:       n.paused,
+			X:            n.x,
+			Y:            n.y,
+		}
+
+		index[n.id] = i
+	}
+
+	for _, c := range chans {
+		n := &nodes[index[c.src]]
+		n.Outs = append(n.Outs, c.info)
+	}
+
+	return nodes, nil
+}
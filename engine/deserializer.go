@@ -0,0 +1,689 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// a node read from the file, with the parameters it will be spawned with
+type nodespec struct {
+	id           NodeID
+	name         string
+	sendText     string
+	sendInterval time.Duration
+	relayMode    RelayMode
+	paused       bool
+	x, y         int
+	ttl          int
+}
+
+// a channel read from the file: its source, and destination and parameters
+type chanspec struct {
+	src  NodeID
+	info ChanInfo
+}
+
+// an attribute value, with the position where it was written
+type attr struct {
+	value     string
+	line, col int
+}
+
+type attrs map[string]attr
+
+func (a attrs) clone() attrs {
+	c := make(attrs, len(a))
+	for k, v := range a {
+		c[k] = v
+	}
+
+	return c
+}
+
+// returns a copy of a with the attributes of b added, replacing those with the
+// same name
+func merge(a, b attrs) attrs {
+	c := a.clone()
+	for k, v := range b {
+		c[k] = v
+	}
+
+	return c
+}
+
+// a node found in the file, identified by its key (its DOT ID, or its ID in
+// other formats)
+type fileNode struct {
+	key       string
+	attrs     attrs
+	line, col int
+
+	// true if the node appears in a node statement, not only in edges
+	// (which is always the case outside of DOT)
+	declared bool
+
+	// line where the node is defined with its attributes, 0 if it is not
+	defLine int
+}
+
+// a channel found in the file, between the nodes with the given keys
+type fileEdge struct {
+	src, dst  string
+	attrs     attrs
+	line, col int
+}
+
+// The graph described by a file, before it is turned into nodes and channels.
+// The readers of the different formats fill it, and then call result.
+type fileGraph struct {
+	// attributes of the graph, only format_version is used
+	graphAttrs attrs
+
+	nodes map[string]*fileNode
+	order []*fileNode // in order of appearance
+	edges []fileEdge
+
+	// true if every node must be defined explicitly, and not just by the
+	// channels which connect it: in files written by older versions of
+	// the program, and in formats other than DOT
+	explicit bool
+
+	// true for strict DOT graphs, where several edges between the same
+	// nodes are merged into one
+	strict bool
+
+	errs ParseErrors
+}
+
+func newFileGraph() *fileGraph {
+	return &fileGraph{
+		graphAttrs: attrs{},
+		nodes:      make(map[string]*fileNode),
+	}
+}
+
+// maximum number of errors reported for a file, parsing stops after that
+const MAX_PARSE_ERRORS = 10
+
+// returned by the parsing functions when there are too many errors to go on
+var errTooManyErrors = errors.New("too many errors")
+
+// Records an error, unless the same error has already been found (which
+// happens when an error makes the enclosing statement fail too, or with invalid
+// default attributes, which are checked for each node).
+// Returns errTooManyErrors when MAX_PARSE_ERRORS have been recorded.
+func (g *fileGraph) addError(err *ParseError) error {
+	if len(g.errs) >= MAX_PARSE_ERRORS {
+		return errTooManyErrors
+	}
+
+	dup := slices.ContainsFunc(g.errs, func(e *ParseError) bool {
+		return *e == *err
+	})
+
+	if !dup {
+		g.errs = append(g.errs, err)
+	}
+
+	if len(g.errs) >= MAX_PARSE_ERRORS {
+		return errTooManyErrors
+	}
+
+	return nil
+}
+
+// Checks the graph and returns the nodes and channels to spawn, or all the
+// errors found in the file, sorted by position.
+func (g *fileGraph) result() ([]nodespec, []chanspec, error) {
+	g.validate()
+	nodes, chans := g.specs()
+
+	if len(g.errs) > 0 {
+		slices.SortStableFunc(g.errs, func(a, b *ParseError) int {
+			if a.Line != b.Line {
+				return a.Line - b.Line
+			}
+
+			return a.Col - b.Col
+		})
+
+		return nil, nil, g.errs
+	}
+
+	return nodes, chans, nil
+}
+
+// returns true if the file was written by the program, by this or an older
+// version, rather than by another tool
+func (g *fileGraph) native() bool {
+	_, versioned := g.graphAttrs["format_version"]
+	return versioned || g.explicit
+}
+
+// Checks that the channels read from the file can be created as they are
+// described: channels from a node to itself and several channels between the
+// same nodes are not supported, except in strict graphs, where the later edges
+// are merged into the first one (with their attributes). In files written by
+// the program, where every node has its own statement, channels must connect
+// nodes defined in the file; in other DOT files, nodes which only appear in
+// edges are created implicitly. Duplicate node definitions are found while
+// parsing.
+func (g *fileGraph) validate() {
+	native := g.native()
+
+	declared := func(key string) bool {
+		n, ok := g.nodes[key]
+		return ok && n.declared
+	}
+
+	// index in edges of the first channel found between each pair of nodes
+	seen := make(map[[2]string]int)
+	edges := make([]fileEdge, 0, len(g.edges))
+
+	for _, e := range g.edges {
+		errorf := func(format string, args ...interface{}) {
+			g.addError(&ParseError{
+				Line:  e.line,
+				Col:   e.col,
+				Token: "'" + e.src + " -> " + e.dst + "'",
+				Msg:   fmt.Sprintf(format, args...),
+			})
+		}
+
+		switch {
+		case native && !declared(e.src):
+			errorf("channel from node %q, which is not defined", e.src)
+
+		case native && !declared(e.dst):
+			errorf("channel to node %q, which is not defined", e.dst)
+
+		case e.src == e.dst:
+			errorf("channel from node %q to itself", e.src)
+
+		default:
+			first, ok := seen[[2]string{e.src, e.dst}]
+
+			switch {
+			case !ok:
+				seen[[2]string{e.src, e.dst}] = len(edges)
+
+			case g.strict:
+				edges[first].attrs = merge(edges[first].attrs, e.attrs)
+				continue
+
+			default:
+				errorf("channel from node %q to node %q is already defined at line %d",
+					e.src, e.dst, edges[first].line)
+			}
+		}
+
+		edges = append(edges, e)
+	}
+
+	g.edges = edges
+}
+
+// space between the nodes placed automatically, which have no position in the
+// file
+const AUTO_LAYOUT_SPACING = 80
+
+// Turns the nodes and edges read from the file into the specifications of the
+// nodes and channels to spawn, assigning their IDs and converting the
+// attributes into parameters. Invalid attributes are recorded as errors.
+func (g *fileGraph) specs() ([]nodespec, []chanspec) {
+	// files written by newer versions of the program may store the
+	// parameters in ways we do not know about
+	if a, ok := g.graphAttrs["format_version"]; ok {
+		if v, err := strconv.Atoi(a.value); err != nil {
+			g.addError(attrError("format_version", a, "integer"))
+		} else if v > FORMAT_VERSION {
+			g.addError(&ParseError{
+				Line:  a.line,
+				Col:   a.col,
+				Token: strconv.Quote(a.value),
+				Msg: fmt.Sprintf("format version %d is not supported, "+
+					"the file was written by a newer version of the program", v),
+			})
+		}
+	}
+
+	ids := make(map[string]NodeID)
+
+	// IDs which are non-negative integers are kept, so that the IDs of
+	// saved networks do not change
+	next := NodeID(0)
+	for _, n := range g.order {
+		if id, err := strconv.Atoi(n.key); err == nil && id >= 0 &&
+			strconv.Itoa(id) == n.key {
+
+			ids[n.key] = NodeID(id)
+			next = max(next, NodeID(id)+1)
+		}
+	}
+
+	for _, n := range g.order {
+		if _, ok := ids[n.key]; !ok {
+			ids[n.key] = next
+			next++
+		}
+	}
+
+	nodes := make([]nodespec, 0, len(g.order))
+	var unplaced []int
+	native := g.native()
+
+	for _, n := range g.order {
+		spec, placed, errs := nodeFromAttrs(ids[n.key], n.key, n.attrs, native)
+		for _, err := range errs {
+			g.addError(err)
+		}
+
+		if !placed {
+			unplaced = append(unplaced, len(nodes))
+		}
+
+		nodes = append(nodes, spec)
+	}
+
+	autoLayout(nodes, unplaced)
+
+	chans := make([]chanspec, 0, len(g.edges))
+
+	for _, e := range g.edges {
+		c := ChanInfo{Dst: ids[e.dst], Link: DEFAULT_LINK}
+
+		for _, err := range chanFromAttrs(&c, e.attrs) {
+			g.addError(err)
+		}
+
+		chans = append(chans, chanspec{ids[e.src], c})
+	}
+
+	return nodes, chans
+}
+
+// Places the nodes at the given indices, which have no position in the file,
+// on a grid below the other nodes.
+func autoLayout(nodes []nodespec, unplaced []int) {
+	if len(unplaced) == 0 {
+		return
+	}
+
+	// below the lowest of the other nodes, if there are any
+	y0 := 0
+	if len(unplaced) < len(nodes) {
+		y0 = math.MinInt
+		for k, n := range nodes {
+			if !slices.Contains(unplaced, k) {
+				y0 = max(y0, n.y)
+			}
+		}
+
+		y0 += AUTO_LAYOUT_SPACING
+	}
+
+	cols := int(math.Ceil(math.Sqrt(float64(len(unplaced)))))
+
+	for i, k := range unplaced {
+		nodes[k].x = (i % cols) * AUTO_LAYOUT_SPACING
+		nodes[k].y = y0 + (i/cols)*AUTO_LAYOUT_SPACING
+	}
+}
+
+// spawns a node read from the file with the given ID, which can be different
+// from the one in the file
+func (net *Network) spawnNode(n nodespec, id NodeID) {
+	net.SpawnWithID(id, n.x, n.y)
+
+	if n.paused {
+		net.TogglePause(id)
+	}
+
+	net.SetName(id, n.name)
+	net.SetSendText(id, n.sendText)
+	net.SetSendInterval(id, n.sendInterval)
+	net.SetRelayMode(id, n.relayMode)
+	net.SetTTL(id, n.ttl)
+}
+
+// creates a channel read from the file between the given nodes
+func (net *Network) spawnChan(c chanspec, src NodeID, dst NodeID) {
+	net.Connect(src, dst)
+	net.SetLink(src, dst, c.info.Link)
+	net.SetOverflow(src, dst, c.info.Overflow, c.info.Timeout)
+}
+
+// An error found while reading a network, at the given line and column of the
+// file (both starting from 1). Token is the offending token, as it would be
+// written in the file, and Expected describes what was expected in its place.
+// Errors which are not about an unexpected token, like unterminated strings,
+// are described by Msg instead.
+type ParseError struct {
+	Line, Col int
+	Token     string
+	Expected  string
+	Msg       string
+}
+
+func (e *ParseError) Error() string {
+	if e.Msg != "" {
+		return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Col, e.Msg)
+	}
+
+	return fmt.Sprintf("line %d, column %d: expected %s, found %s",
+		e.Line, e.Col, e.Expected, e.Token)
+}
+
+// All the errors found in a file, sorted by position. The parser goes on after
+// an error, so several independent errors are reported at once.
+type ParseErrors []*ParseError
+
+// one error per line
+func (errs ParseErrors) Error() string {
+	lines := make([]string, len(errs))
+	for i, e := range errs {
+		lines[i] = e.Error()
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// returns the error for an attribute with an invalid value
+func attrError(key string, a attr, expected string) *ParseError {
+	return &ParseError{
+		Line:     a.line,
+		Col:      a.col,
+		Token:    strconv.Quote(a.value),
+		Expected: expected + " for " + key,
+	}
+}
+
+// Returns the parameters of the node with the given ID and key from its
+// attributes. Unknown attributes are ignored, and missing ones take the
+// default values of new nodes; the second return value is false if the node
+// has no position. All the invalid attributes are returned as errors. Labels
+// are taken as they are in files written by the program (native), where they
+// are just the names of the nodes.
+func nodeFromAttrs(id NodeID, key string, attrs attrs, native bool) (nodespec, bool, ParseErrors) {
+	n := nodespec{
+		id:        id,
+		name:      key,
+		sendText:  "from " + strconv.Itoa(int(id)),
+		relayMode: ROUND_ROBIN,
+	}
+
+	placed := false
+	var errs ParseErrors
+
+	for k, a := range attrs {
+		var err error
+		var ms int
+
+		// description of the valid values, for the error
+		expected := ""
+
+		switch k {
+		case "label":
+			// as in Graphviz, \N stands for the ID of the node in
+			// files written by other tools
+			n.name = a.value
+			if !native {
+				n.name = strings.ReplaceAll(n.name, "\\N", key)
+			}
+
+		case "name":
+			// the name as it is, used by the formats other than DOT;
+			// label takes precedence
+			if _, ok := attrs["label"]; !ok {
+				n.name = a.value
+			}
+
+		case "send_text":
+			n.sendText = a.value
+
+		case "interval_ms":
+			ms, err = strconv.Atoi(a.value)
+			if err == nil && ms < 0 {
+				err = errors.New("negative interval")
+			}
+			n.sendInterval = time.Duration(ms) * time.Millisecond
+			expected = "non-negative integer"
+
+		case "relay":
+			var ok bool
+			if n.relayMode, ok = ParseRelayMode(a.value); !ok {
+				err = errors.New("unknown relay mode")
+			}
+			expected = "round_robin, multicast or discard"
+
+		case "paused":
+			n.paused, err = strconv.ParseBool(a.value)
+			expected = "true or false"
+
+		case "pos":
+			// Graphviz coordinates, y grows upwards
+			n.x, n.y, err = parsePos(a.value)
+			n.y = -n.y
+			placed = err == nil
+			expected = "position x,y"
+
+		case "ttl":
+			n.ttl, err = strconv.Atoi(a.value)
+			if err == nil && n.ttl < 0 {
+				err = errors.New("negative TTL")
+			}
+			expected = "non-negative integer"
+		}
+
+		if err != nil {
+			errs = append(errs, attrError(k, a, expected))
+		}
+	}
+
+	return n, placed, errs
+}
+
+// Parses a position in the Graphviz format "x,y", optionally followed by "!"
+// (which means that the position is fixed, as it always is for us).
+func parsePos(s string) (int, int, error) {
+	xs, ys, ok := strings.Cut(strings.TrimSuffix(s, "!"), ",")
+	if !ok {
+		return 0, 0, errors.New("expected x,y")
+	}
+
+	x, err := strconv.ParseFloat(strings.TrimSpace(xs), 64)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	y, err := strconv.ParseFloat(strings.TrimSpace(ys), 64)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return int(math.Round(x)), int(math.Round(y)), nil
+}
+
+// Sets the fields of c from the channel attributes written by the serializer.
+// Unknown attributes are ignored. All the invalid attributes are returned as
+// errors.
+func chanFromAttrs(c *ChanInfo, attrs attrs) ParseErrors {
+	var errs ParseErrors
+
+	for k, a := range attrs {
+		var err error
+		var ms int
+
+		// description of the valid values, for the error
+		expected := "non-negative integer"
+
+		switch k {
+		case "buf_size":
+			c.Link.BufSize, err = strconv.Atoi(a.value)
+			if err == nil && c.Link.BufSize < 0 {
+				err = errors.New("negative buffer size")
+			}
+
+		case "delay_ms":
+			ms, err = strconv.Atoi(a.value)
+			if err == nil && ms < 0 {
+				err = errors.New("negative delay")
+			}
+			c.Link.Delay = time.Duration(ms) * time.Millisecond
+
+		case "jitter_ms":
+			ms, err = strconv.Atoi(a.value)
+			if err == nil && ms < 0 {
+				err = errors.New("negative jitter")
+			}
+			c.Link.Jitter = time.Duration(ms) * time.Millisecond
+
+		case "loss":
+			c.Link.Loss, err = strconv.ParseFloat(a.value, 64)
+			if err == nil && (c.Link.Loss < 0 || c.Link.Loss > 1) {
+				err = errors.New("loss probability out of range")
+			}
+			expected = "probability between 0 and 1"
+
+		case "overflow":
+			var ok bool
+			if c.Overflow, ok = ParseOverflowPolicy(a.value); !ok {
+				err = errors.New("unknown overflow policy")
+			}
+			expected = "block, drop_newest, drop_oldest or block_timeout"
+
+		case "timeout_ms":
+			ms, err = strconv.Atoi(a.value)
+			if err == nil && ms < 0 {
+				err = errors.New("negative timeout")
+			}
+			c.Timeout = time.Duration(ms) * time.Millisecond
+		}
+
+		if err != nil {
+			errs = append(errs, attrError(k, a, expected))
+		}
+	}
+
+	return errs
+}
+
+// Deserializes a network stored in the format of the given codec, and spawns
+// its nodes in `net`. The DOT codec reads the files written by the program, in
+// the format described in the README.org file, as well as any other DOT file.
+// The network read replaces the current one: once the whole file has been read
+// and checked, all running nodes are stopped with StopAllAndWait. If the file
+// cannot be parsed, the network is left untouched and the error is returned;
+// errors in the contents of the file are ParseErrors, listing all the problems
+// found.
+func (net *Network) Deserialize(reader io.Reader, c Codec) error {
+	nodes, chans, err := c.decode(reader)
+	if err != nil {
+		return err
+	}
+
+	if len(net.nodes) > 0 {
+		net.StopAllAndWait()
+	}
+
+	for _, n := range nodes {
+		net.spawnNode(n, n.id)
+		net.order = append(net.order, n.id)
+	}
+
+	// the channels are added after all nodes have been created, since they
+	// can appear in the file before their endpoints
+	for _, c := range chans {
+		net.spawnChan(c, c.src, c.info.Dst)
+	}
+
+	return nil
+}
+
+// Adds the nodes stored in the format of the given codec to the network,
+// keeping the running ones. Each node gets a fresh ID, and positions are
+// translated so that the center of the new nodes is at (x, y). Channels towards
+// nodes which are not in the input are ignored.
+// Returns the IDs of the new nodes, or the same errors as Deserialize.
+func (net *Network) Merge(reader io.Reader, c Codec, x, y int) ([]NodeID, error) {
+	return net.merge(reader, c, x, y, false)
+}
+
+// Same as Merge, but the nodes keep their IDs, unless they are already in use:
+// only those get fresh IDs, above all the others. Used to import files.
+func (net *Network) Import(reader io.Reader, c Codec, x, y int) ([]NodeID, error) {
+	return net.merge(reader, c, x, y, true)
+}
+
+// implements Merge and Import; keepIDs tells whether the free IDs are kept
+func (net *Network) merge(reader io.Reader, c Codec, x, y int, keepIDs bool) ([]NodeID, error) {
+	nodes, chans, err := c.decode(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(nodes) == 0 {
+		return nil, nil
+	}
+
+	// center of the bounding box of the nodes
+	x0, y0 := nodes[0].x, nodes[0].y
+	x1, y1 := x0, y0
+
+	for _, n := range nodes {
+		x0, y0 = min(x0, n.x), min(y0, n.y)
+		x1, y1 = max(x1, n.x), max(y1, n.y)
+	}
+
+	dx, dy := x-(x0+x1)/2, y-(y0+y1)/2
+
+	// IDs in the input -> IDs in the network; the free ones may be kept,
+	// and the fresh IDs must not clash with them either
+	ids := make(map[NodeID]NodeID)
+	next := net.nextID
+
+	for _, n := range nodes {
+		if _, used := net.nodes[n.id]; keepIDs && !used {
+			ids[n.id] = n.id
+			next = max(next, n.id+1)
+		}
+	}
+
+	for _, n := range nodes {
+		if _, ok := ids[n.id]; !ok {
+			ids[n.id] = next
+			next++
+		}
+	}
+
+	newIDs := make([]NodeID, 0, len(nodes))
+
+	for _, n := range nodes {
+		n.x += dx
+		n.y += dy
+
+		id := ids[n.id]
+		net.spawnNode(n, id)
+
+		newIDs = append(newIDs, id)
+	}
+
+	net.order = append(net.order, newIDs...)
+
+	for _, c := range chans {
+		src, ok1 := ids[c.src]
+		dst, ok2 := ids[c.info.Dst]
+
+		if ok1 && ok2 {
+			net.spawnChan(c, src, dst)
+		}
+	}
+
+	return newIDs, nil
+}
@@ -0,0 +1,238 @@
+package engine
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// in strict graphs, the edges between the same nodes are merged, with the
+// attributes of the later ones
+func TestStrictGraphMergesEdges(t *testing.T) {
+	_, chans, err := DOT.decode(strings.NewReader(
+		"strict digraph { a -> b; a -> b [delay_ms=5]; b -> a }"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(chans) != 2 || chans[0].info.Link.Delay != 5*time.Millisecond {
+		t.Errorf("duplicate edges not merged: %+v", chans)
+	}
+
+	_, chans, err = DOT.decode(strings.NewReader(
+		"strict graph { c -- d [loss=0.5]; d -- c [buf_size=3] }"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range chans {
+		if len(chans) != 2 || c.info.Link.Loss != 0.5 || c.info.Link.BufSize != 3 {
+			t.Errorf("duplicate edges not merged: %+v", chans)
+		}
+	}
+
+	if _, _, err := DOT.decode(strings.NewReader("digraph { a -> b; a -> b }")); err == nil {
+		t.Error("duplicate edge accepted in a graph which is not strict")
+	}
+}
+
+// header of the files written by the program
+const v2 = "digraph network {\ngraph [format_version=2]\n"
+
+// All the errors in a file are reported, sorted by position.
+func TestDeserializeErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		codec Codec
+		src   string
+		errs  []string
+	}{
+		{
+			"duplicate node", DOT,
+			v2 + "0 [label=\"a\"]\n0 [label=\"b\"]\n}\n",
+			[]string{`line 4, column 1: node "0" is already defined at line 3`},
+		},
+		{
+			"self-loop", DOT,
+			v2 + "0 [label=\"a\"]\n0 -> 0\n}\n",
+			[]string{`line 4, column 3: channel from node "0" to itself`},
+		},
+		{
+			"duplicate edge", DOT,
+			v2 + "0 [label=\"a\"]\n1 [label=\"b\"]\n0 -> 1\n0 -> 1 [buf_size=2]\n}\n",
+			[]string{`line 6, column 3: channel from node "0" to node "1" is already defined at line 5`},
+		},
+		{
+			"edge to undefined node", DOT,
+			v2 + "0 [label=\"a\"]\n0 -> 1\n}\n",
+			[]string{`line 4, column 3: channel to node "1", which is not defined`},
+		},
+		{
+			"unknown relay mode", DOT,
+			v2 + "0 [label=\"a\", relay=broadcast]\n}\n",
+			[]string{`line 3, column 21: expected round_robin, multicast or discard for relay, found "broadcast"`},
+		},
+		{
+			"relay mode out of range", DOT,
+			"digraph network {\n0 [label=\"a\"] // \"from 0\" 1000 3 false 10 20\n}\n",
+			[]string{`line 2, column 32: expected round_robin, multicast or discard for relay, found "3"`},
+		},
+		{
+			"negative link parameters", DOT,
+			v2 + "0 [label=\"a\"]\n1 [label=\"b\"]\n0 -> 1 [delay_ms=-3, jitter_ms=-4, timeout_ms=-1]\n}\n",
+			[]string{
+				`line 5, column 18: expected non-negative integer for delay_ms, found "-3"`,
+				`line 5, column 32: expected non-negative integer for jitter_ms, found "-4"`,
+				`line 5, column 47: expected non-negative integer for timeout_ms, found "-1"`,
+			},
+		},
+		{
+			"several errors", DOT,
+			v2 + "0 [label=\"a\", ttl=-1]\n1 [label=\"b\", interval_ms=x]\n1 -> 1\n0 -> 2 [loss=2]\n}\n",
+			[]string{
+				`line 3, column 19: expected non-negative integer for ttl, found "-1"`,
+				`line 4, column 27: expected non-negative integer for interval_ms, found "x"`,
+				`line 5, column 3: channel from node "1" to itself`,
+				`line 6, column 3: channel to node "2", which is not defined`,
+				`line 6, column 14: expected probability between 0 and 1 for loss, found "2"`,
+			},
+		},
+		{
+			"several errors in JSON", JSON,
+			`{"format_version": 2, "nodes": [{"id": 0, "relay": "3"}, {"id": 0}], "channels": [{"src": 0, "dst": 0}]}`,
+			[]string{
+				`line 1, column 33: expected round_robin, multicast or discard for relay, found "3"`,
+				`line 1, column 58: node "0" is already defined at line 1`,
+				`line 1, column 83: channel from node "0" to itself`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := tt.codec.decode(strings.NewReader(tt.src))
+
+			errs, ok := err.(ParseErrors)
+			if !ok {
+				t.Fatalf("expected ParseErrors, got %v", err)
+			}
+
+			if got := strings.Split(errs.Error(), "\n"); !slices.Equal(got, tt.errs) {
+				t.Errorf("errors:\n%s\nexpected:\n%s",
+					strings.Join(got, "\n"), strings.Join(tt.errs, "\n"))
+			}
+		})
+	}
+}
+
+// a file with errors does not replace the running network
+func TestDeserializeErrorKeepsNetwork(t *testing.T) {
+	net := New()
+	defer net.StopAllAndWait()
+
+	valid := v2 + "0 [label=\"a\"]\n1 [label=\"b\"]\n0 -> 1\n}\n"
+	if err := net.Deserialize(strings.NewReader(valid), DOT); err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := v2 + "0 [label=\"c\"]\n0 -> 1\n}\n"
+	if err := net.Deserialize(strings.NewReader(invalid), DOT); err == nil {
+		t.Fatal("channel to an undefined node accepted")
+	}
+
+	if err := net.Deserialize(strings.NewReader(""), GRAPHML); err == nil {
+		t.Fatal("GraphML file loaded")
+	}
+
+	if n, _ := net.Node(0); len(net.Nodes()) != 2 || n.Name != "a" || !net.Connected(0, 1) {
+		t.Errorf("network changed after a failed load: %v", net.Nodes())
+	}
+}
+
+// in DOT files written by other tools, \N in labels still stands for the ID
+func TestForeignLabelNodeID(t *testing.T) {
+	nodes, _, err := DOT.decode(strings.NewReader(`digraph { a [label="node \N"]; a -> b }`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if nodes[0].name != "node a" {
+		t.Errorf("label loaded as %q, expected %q", nodes[0].name, "node a")
+	}
+}
+
+// imported nodes keep their IDs, unless they clash with the running ones
+func TestImportKeepsFreeIDs(t *testing.T) {
+	net := New()
+	defer net.StopAllAndWait()
+
+	net.Spawn(0, 0)
+	net.Spawn(0, 0)
+
+	src := v2 + "1 [label=\"c\"]\n5 [label=\"d\"]\n1 -> 5\n5 -> 1\n}\n"
+
+	ids, err := net.Import(strings.NewReader(src), DOT, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(ids, []NodeID{6, 5}) {
+		t.Fatalf("imported nodes got IDs %v, expected [6 5]", ids)
+	}
+
+	if n, _ := net.Node(1); n.Name == "c" {
+		t.Error("running node 1 replaced by the imported one")
+	}
+
+	if !net.Connected(6, 5) || !net.Connected(5, 6) {
+		t.Error("channels between the imported nodes not renumbered")
+	}
+
+	if id := net.Spawn(0, 0); id != 7 {
+		t.Errorf("new node got ID %v after a merge, expected 7", id)
+	}
+}
+
+// merged nodes always get fresh IDs
+func TestMergeRenumbersAll(t *testing.T) {
+	net := New()
+	defer net.StopAllAndWait()
+
+	net.Spawn(0, 0)
+
+	src := v2 + "1 [label=\"c\"]\n5 [label=\"d\"]\n1 -> 5\n}\n"
+
+	ids, err := net.Merge(strings.NewReader(src), DOT, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(ids, []NodeID{1, 2}) || !net.Connected(1, 2) {
+		t.Errorf("merged nodes got IDs %v, expected [1 2]", ids)
+	}
+}
+
+// ReadNodes returns the nodes of the file with their channels, without
+// spawning them
+func TestReadNodes(t *testing.T) {
+	src := v2 + "0 [label=\"a\", interval_ms=10]\n1 [label=\"b\", paused=true]\n" +
+		"0 -> 1 [delay_ms=5]\n}\n"
+
+	nodes, err := ReadNodes(strings.NewReader(src), DOT)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(nodes) != 2 || nodes[0].Name != "a" || !nodes[1].Paused {
+		t.Fatalf("nodes read as %+v", nodes)
+	}
+
+	if o := nodes[0].Outs; len(o) != 1 || o[0].Dst != 1 || o[0].Link.Delay != 5*time.Millisecond {
+		t.Errorf("channels read as %+v", o)
+	}
+
+	if nodes[0].Received() != 0 || nodes[0].Outs[0].Queued() != 0 {
+		t.Error("nodes which were never spawned have statistics")
+	}
+}
@@ -0,0 +1,952 @@
+package engine
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// This file implements a reader for the DOT language used by Graphviz, as
+// described in https://graphviz.org/doc/info/lang.html. It supports the whole
+// grammar (attribute lists, chains of edges, subgraphs, node and edge default
+// attributes, comments) and maps the attributes it knows onto the parameters of
+// nodes and channels; the others are ignored.
+//
+// Files written by older versions of the program store the parameters of the
+// nodes in a comment after each node statement, e.g.
+//
+//	0 [label="node"] // "from 0" 1000 0 false 100 200 0
+//
+// such comments are also read.
+
+// kinds of the tokens of the DOT language
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokID            // bare word, numeral, quoted string or HTML string
+	tokLBrace
+	tokRBrace
+	tokLBracket
+	tokRBracket
+	tokSemicolon
+	tokComma
+	tokEqual
+	tokColon
+	tokEdgeOp // -> or --
+)
+
+// a line comment, with the position where it starts
+type comment struct {
+	text      string
+	line, col int
+}
+
+type token struct {
+	kind tokenKind
+
+	// for tokID the value of the identifier, with quotes and escape
+	// sequences removed; for the other kinds, the token itself
+	text string
+
+	// true if the identifier was a quoted or HTML string, which are never
+	// keywords
+	quoted bool
+
+	// position of the first character of the token, starting from 1
+	line, col int
+
+	// line comments found before the token, after the previous one
+	comments []comment
+}
+
+// describes the token for error messages
+func (t token) String() string {
+	switch {
+	case t.kind == tokEOF:
+		return "end of file"
+	case t.quoted:
+		return strconv.Quote(t.text)
+	default:
+		return "'" + t.text + "'"
+	}
+}
+
+// returns true if the token is the given keyword; keywords are case insensitive
+func (t token) is(keyword string) bool {
+	return t.kind == tokID && !t.quoted && strings.EqualFold(t.text, keyword)
+}
+
+type lexer struct {
+	src       []rune
+	pos       int
+	line, col int
+}
+
+func newLexer(src string) *lexer {
+	return &lexer{src: []rune(src), line: 1, col: 1}
+}
+
+// returns the character at offset i from the current one, or 0 at the end of
+// the input
+func (l *lexer) peek(i int) rune {
+	if l.pos+i >= len(l.src) {
+		return 0
+	}
+
+	return l.src[l.pos+i]
+}
+
+// moves to the next character, keeping track of lines and columns
+func (l *lexer) advance() rune {
+	c := l.src[l.pos]
+	l.pos++
+
+	if c == '\n' {
+		l.line++
+		l.col = 1
+	} else {
+		l.col++
+	}
+
+	return c
+}
+
+func (l *lexer) errorf(line, col int, format string, args ...interface{}) *ParseError {
+	return &ParseError{Line: line, Col: col, Msg: fmt.Sprintf(format, args...)}
+}
+
+// Skips spaces and comments, collecting the line comments. Lines starting
+// with # are output of the C preprocessor, and are skipped like comments.
+func (l *lexer) skipSpace() ([]comment, error) {
+	var comments []comment
+
+	for l.pos < len(l.src) {
+		c := l.peek(0)
+
+		switch {
+		case unicode.IsSpace(c):
+			l.advance()
+
+		case c == '#' && l.col == 1:
+			l.skipLine()
+
+		case c == '/' && l.peek(1) == '/':
+			line, col := l.line, l.col
+			l.advance()
+			l.advance()
+
+			start := l.pos
+			l.skipLine()
+
+			text := strings.TrimSpace(string(l.src[start:l.pos]))
+			comments = append(comments, comment{text, line, col})
+
+		case c == '/' && l.peek(1) == '*':
+			line, col := l.line, l.col
+			l.advance()
+			l.advance()
+
+			for !(l.peek(0) == '*' && l.peek(1) == '/') {
+				if l.pos >= len(l.src) {
+					return nil, l.errorf(line, col, "unterminated comment")
+				}
+
+				l.advance()
+			}
+
+			l.advance()
+			l.advance()
+
+		default:
+			return comments, nil
+		}
+	}
+
+	return comments, nil
+}
+
+// moves to the end of the line, without consuming the newline
+func (l *lexer) skipLine() {
+	for l.pos < len(l.src) && l.peek(0) != '\n' {
+		l.advance()
+	}
+}
+
+// Returns the next token of the input. After an error the lexer can be called
+// again, and continues after the invalid text.
+func (l *lexer) next() (token, error) {
+	comments, err := l.skipSpace()
+	if err != nil {
+		return token{}, err
+	}
+
+	t := token{line: l.line, col: l.col, comments: comments}
+
+	if l.pos >= len(l.src) {
+		t.kind = tokEOF
+		return t, nil
+	}
+
+	punct := map[rune]tokenKind{
+		'{': tokLBrace, '}': tokRBrace,
+		'[': tokLBracket, ']': tokRBracket,
+		';': tokSemicolon, ',': tokComma,
+		'=': tokEqual, ':': tokColon,
+	}
+
+	c := l.peek(0)
+
+	switch {
+	case punct[c] != tokEOF:
+		t.kind = punct[c]
+		t.text = string(l.advance())
+
+	case c == '-' && (l.peek(1) == '>' || l.peek(1) == '-'):
+		t.kind = tokEdgeOp
+		t.text = string(l.advance()) + string(l.advance())
+
+	case c == '"':
+		t.kind = tokID
+		t.quoted = true
+		t.text, err = l.quoted()
+
+	case c == '<':
+		t.kind = tokID
+		t.quoted = true
+		t.text, err = l.html()
+
+	case c == '-' || c == '.' || isDigit(c):
+		t.kind = tokID
+		t.text, err = l.numeral()
+
+	case isIDStart(c):
+		t.kind = tokID
+		t.text = l.word()
+
+	default:
+		err := l.errorf(l.line, l.col, "unexpected character %q", c)
+		err.Token = strconv.QuoteRune(c)
+		l.advance()
+
+		return t, err
+	}
+
+	return t, err
+}
+
+func isDigit(c rune) bool {
+	return c >= '0' && c <= '9'
+}
+
+// bare identifiers are made of letters, digits and underscores, and any
+// non-ASCII character, and do not start with a digit
+func isIDStart(c rune) bool {
+	return c == '_' || c >= 0x80 || unicode.IsLetter(c)
+}
+
+func (l *lexer) word() string {
+	start := l.pos
+
+	for l.pos < len(l.src) && (isIDStart(l.peek(0)) || isDigit(l.peek(0))) {
+		l.advance()
+	}
+
+	return string(l.src[start:l.pos])
+}
+
+// reads a numeral: [-]( .[0-9]+ | [0-9]+(.[0-9]*)? )
+func (l *lexer) numeral() (string, error) {
+	line, col := l.line, l.col
+	start := l.pos
+
+	if l.peek(0) == '-' {
+		l.advance()
+	}
+
+	digits := 0
+	for isDigit(l.peek(0)) {
+		l.advance()
+		digits++
+	}
+
+	if l.peek(0) == '.' {
+		l.advance()
+		for isDigit(l.peek(0)) {
+			l.advance()
+			digits++
+		}
+	}
+
+	if digits == 0 {
+		return "", l.errorf(line, col, "invalid numeral %q", string(l.src[start:l.pos]))
+	}
+
+	return string(l.src[start:l.pos]), nil
+}
+
+// Reads a quoted string. Quoted strings can be concatenated with +, as in
+// "abc" + "def". The escape sequences are those of Go string literals, which
+// the serializer writes; strings which are not valid Go literals are read with
+// the rules of DOT, where only \" is an escape sequence and other backslashes
+// are kept (Graphviz uses them in labels, e.g. \n, \l).
+func (l *lexer) quoted() (string, error) {
+	s := ""
+
+	for {
+		line, col := l.line, l.col
+		start := l.pos
+
+		l.advance() // opening quote
+
+		for l.peek(0) != '"' {
+			if l.pos >= len(l.src) {
+				return "", l.errorf(line, col, "unterminated string")
+			}
+
+			if l.advance() == '\\' && l.pos < len(l.src) {
+				l.advance()
+			}
+		}
+
+		l.advance() // closing quote
+
+		raw := string(l.src[start:l.pos])
+
+		if v, err := strconv.Unquote(raw); err == nil {
+			s += v
+		} else {
+			s += strings.ReplaceAll(raw[1:len(raw)-1], "\\\"", "\"")
+		}
+
+		// look for a + followed by another string, without consuming
+		// anything if there is none
+		pos, line, col := l.pos, l.line, l.col
+
+		if _, err := l.skipSpace(); err != nil || l.peek(0) != '+' {
+			l.pos, l.line, l.col = pos, line, col
+			return s, nil
+		}
+
+		l.advance()
+
+		if _, err := l.skipSpace(); err != nil || l.peek(0) != '"' {
+			l.pos, l.line, l.col = pos, line, col
+			return s, nil
+		}
+	}
+}
+
+// reads an HTML string, i.e. text between balanced < and >; the value is the
+// text between the outer brackets
+func (l *lexer) html() (string, error) {
+	line, col := l.line, l.col
+	start := l.pos
+	depth := 0
+
+	for {
+		if l.pos >= len(l.src) {
+			return "", l.errorf(line, col, "unterminated HTML string")
+		}
+
+		switch l.advance() {
+		case '<':
+			depth++
+		case '>':
+			depth--
+		}
+
+		if depth == 0 {
+			return string(l.src[start+1 : l.pos-1]), nil
+		}
+	}
+}
+
+// default attributes of the nodes and edges created in a (sub)graph
+type scope struct {
+	node, edge attrs
+}
+
+type dotParser struct {
+	lex *lexer
+	tok token // current token
+
+	// line of the last token consumed, to find the comments on the same
+	// line of a statement
+	prevLine int
+
+	directed bool
+
+	// the graph read so far
+	*fileGraph
+
+	// true if the end of the file was reached by an unterminated string or
+	// comment; the errors found there are a consequence of that, and are
+	// not reported
+	truncated bool
+}
+
+// Records an error like fileGraph.addError, but ignores the errors at the end
+// of a truncated file.
+func (p *dotParser) addError(err *ParseError) error {
+	if p.truncated && p.tok.kind == tokEOF &&
+		err.Line == p.tok.line && err.Col == p.tok.col {
+
+		return nil
+	}
+
+	return p.fileGraph.addError(err)
+}
+
+// Reads the next token. Invalid tokens are recorded as errors and skipped, so
+// that the parser only sees valid ones.
+func (p *dotParser) advance() {
+	p.prevLine = p.tok.line
+
+	for {
+		t, err := p.lex.next()
+		if err == nil {
+			p.tok = t
+			return
+		}
+
+		if p.addError(err.(*ParseError)) != nil {
+			// pretend the file ends here
+			p.tok = token{kind: tokEOF, line: t.line, col: t.col}
+			return
+		}
+
+		p.truncated = p.lex.pos >= len(p.lex.src)
+	}
+}
+
+// returns the error for the current token, in place of which the parser
+// expected something else
+func (p *dotParser) unexpected(expected string) *ParseError {
+	return &ParseError{
+		Line:     p.tok.line,
+		Col:      p.tok.col,
+		Token:    p.tok.String(),
+		Expected: expected,
+	}
+}
+
+// consumes the current token, which must be of the given kind
+func (p *dotParser) expect(kind tokenKind, what string) error {
+	if p.tok.kind != kind {
+		return p.unexpected(what)
+	}
+
+	p.advance()
+	return nil
+}
+
+// Skips the rest of a statement with a syntax error, so that parsing can go on
+// with the following statements. The statement is considered over at the next
+// ';', at the '}' which closes the enclosing (sub)graph, or at the first token
+// on a line after the one of the error.
+func (p *dotParser) sync() {
+	line := p.tok.line
+	depth := 0 // of the braces skipped
+
+	for p.tok.kind != tokEOF {
+		switch {
+		case p.tok.kind == tokRBracket && depth == 0:
+			// the end of an attribute list which spans several
+			// lines, the rest of its line is skipped too
+			line = p.tok.line
+
+		case depth == 0 && p.tok.line > line:
+			return
+
+		case p.tok.kind == tokLBrace:
+			depth++
+
+		case p.tok.kind == tokRBrace:
+			if depth == 0 {
+				return
+			}
+
+			depth--
+
+			// the statement may go on after a subgraph
+			line = p.tok.line
+
+		case p.tok.kind == tokSemicolon && depth == 0:
+			p.advance()
+			return
+		}
+
+		p.advance()
+	}
+}
+
+// Parses a DOT graph, returning the nodes and channels it describes. Node IDs
+// which are non-negative integers are kept, the other nodes are given the
+// following IDs in order of appearance.
+//
+// Syntax errors do not stop the parser, which skips the statement where they
+// are found and goes on, so that all the errors in the file are reported at
+// once, as ParseErrors.
+func parseDOT(reader io.Reader) ([]nodespec, []chanspec, error) {
+	src, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	p := &dotParser{
+		lex:       newLexer(string(src)),
+		fileGraph: newFileGraph(),
+	}
+
+	p.advance()
+
+	if err := p.graph(); err != nil && err != errTooManyErrors {
+		p.addError(err.(*ParseError))
+	}
+
+	// the graph is checked even if there are syntax errors, to find all
+	// the problems with the file
+	return p.result()
+}
+
+// graph : [ 'strict' ] ( 'graph' | 'digraph' ) [ ID ] '{' stmt_list '}'
+func (p *dotParser) graph() error {
+	if p.tok.is("strict") {
+		p.strict = true
+		p.advance()
+	}
+
+	switch {
+	case p.tok.is("digraph"):
+		p.directed = true
+	case p.tok.is("graph"):
+		p.directed = false
+	default:
+		return p.unexpected("'digraph' or 'graph'")
+	}
+
+	p.advance()
+
+	// the name of the graph is ignored
+	if p.tok.kind == tokID {
+		p.advance()
+	}
+
+	if err := p.expect(tokLBrace, "'{'"); err != nil {
+		return err
+	}
+
+	if _, err := p.stmtList(scope{attrs{}, attrs{}}); err != nil {
+		return err
+	}
+
+	if err := p.expect(tokRBrace, "'}'"); err != nil {
+		return err
+	}
+
+	return p.expect(tokEOF, "end of file")
+}
+
+// Parses statements up to the closing brace of the (sub)graph, which is not
+// consumed. Returns the nodes which appear in them. Statements with errors are
+// skipped; the only error returned is errTooManyErrors.
+func (p *dotParser) stmtList(sc scope) ([]string, error) {
+	var members []string
+
+	for p.tok.kind != tokRBrace && p.tok.kind != tokEOF {
+		nodes, err := p.stmt(&sc)
+
+		if err == errTooManyErrors {
+			return nil, err
+		}
+
+		if err != nil {
+			if err := p.addError(err.(*ParseError)); err != nil {
+				return nil, err
+			}
+
+			p.sync()
+			continue
+		}
+
+		members = append(members, nodes...)
+
+		if p.tok.kind == tokSemicolon {
+			p.advance()
+		}
+	}
+
+	return members, nil
+}
+
+// stmt : node_stmt | edge_stmt | attr_stmt | ID '=' ID | subgraph
+func (p *dotParser) stmt(sc *scope) ([]string, error) {
+	switch {
+	case p.tok.is("graph") || p.tok.is("node") || p.tok.is("edge"):
+		// attr_stmt : ( 'graph' | 'node' | 'edge' ) attr_list
+		kind := strings.ToLower(p.tok.text)
+		p.advance()
+
+		a, err := p.attrList()
+		if err != nil {
+			return nil, err
+		}
+
+		// the defaults only apply to the nodes and edges created
+		// afterwards
+		switch kind {
+		case "graph":
+			p.graphAttrs = merge(p.graphAttrs, a)
+		case "node":
+			sc.node = merge(sc.node, a)
+		case "edge":
+			sc.edge = merge(sc.edge, a)
+		}
+
+		return nil, nil
+
+	case p.tok.is("subgraph") || p.tok.kind == tokLBrace:
+		nodes, err := p.subgraph(*sc)
+		if err != nil {
+			return nil, err
+		}
+
+		return p.edgeStmt(sc, nodes)
+
+	case p.tok.kind == tokID:
+		keyTok := p.tok
+		key := keyTok.text
+		p.advance()
+
+		// ID '=' ID sets an attribute of the graph
+		if p.tok.kind == tokEqual {
+			p.advance()
+			value := attr{p.tok.text, p.tok.line, p.tok.col}
+
+			if err := p.expect(tokID, "attribute value"); err != nil {
+				return nil, err
+			}
+
+			p.graphAttrs[key] = value
+			return nil, nil
+		}
+
+		if err := p.port(); err != nil {
+			return nil, err
+		}
+
+		n := p.node(keyTok, sc)
+
+		if p.tok.kind == tokEdgeOp {
+			return p.edgeStmt(sc, []string{key})
+		}
+
+		// node_stmt : node_id [ attr_list ]
+		n.declared = true
+
+		if p.tok.kind == tokLBracket {
+			a, err := p.attrList()
+			if err != nil {
+				return nil, err
+			}
+
+			// DOT allows to add attributes to a node in several
+			// statements, but a file where a node is defined twice
+			// has probably been edited by mistake
+			if n.defLine != 0 {
+				err := &ParseError{
+					Line:  keyTok.line,
+					Col:   keyTok.col,
+					Token: keyTok.String(),
+					Msg: fmt.Sprintf("node %q is already defined at line %d",
+						key, n.defLine),
+				}
+
+				if err := p.addError(err); err != nil {
+					return nil, err
+				}
+			} else {
+				n.defLine = keyTok.line
+			}
+
+			n.attrs = merge(n.attrs, a)
+		}
+
+		// an invalid comment does not invalidate the statement
+		if err := p.legacyComment(n); err != nil {
+			if err := p.addError(err); err != nil {
+				return nil, err
+			}
+		}
+
+		return []string{key}, nil
+
+	default:
+		return nil, p.unexpected("statement")
+	}
+}
+
+// subgraph : [ 'subgraph' [ ID ] ] '{' stmt_list '}'
+// The subgraph has its own copy of the default attributes.
+func (p *dotParser) subgraph(sc scope) ([]string, error) {
+	if p.tok.is("subgraph") {
+		p.advance()
+
+		if p.tok.kind == tokID {
+			p.advance()
+		}
+	}
+
+	if err := p.expect(tokLBrace, "'{'"); err != nil {
+		return nil, err
+	}
+
+	nodes, err := p.stmtList(scope{sc.node.clone(), sc.edge.clone()})
+	if err != nil {
+		return nil, err
+	}
+
+	return nodes, p.expect(tokRBrace, "'}'")
+}
+
+// port : ':' ID [ ':' compass_pt ], ignored
+func (p *dotParser) port() error {
+	for i := 0; i < 2 && p.tok.kind == tokColon; i++ {
+		p.advance()
+
+		if err := p.expect(tokID, "port"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Parses the rest of an edge statement, whose first endpoint (a node or the
+// nodes of a subgraph) has already been read; if there is no edge operator,
+// the statement was just the endpoint.
+//
+//	edge_stmt : ( node_id | subgraph ) edgeRHS [ attr_list ]
+//	edgeRHS   : edgeop ( node_id | subgraph ) [ edgeRHS ]
+func (p *dotParser) edgeStmt(sc *scope, first []string) ([]string, error) {
+	if p.tok.kind != tokEdgeOp {
+		return first, nil
+	}
+
+	line, col := p.tok.line, p.tok.col
+
+	endpoints := [][]string{first}
+	members := slices.Clone(first)
+
+	for p.tok.kind == tokEdgeOp {
+		if p.directed && p.tok.text != "->" {
+			return nil, p.unexpected("'->' in a digraph")
+		}
+
+		if !p.directed && p.tok.text != "--" {
+			return nil, p.unexpected("'--' in a graph")
+		}
+
+		p.advance()
+
+		var nodes []string
+
+		switch {
+		case p.tok.is("subgraph") || p.tok.kind == tokLBrace:
+			var err error
+			if nodes, err = p.subgraph(*sc); err != nil {
+				return nil, err
+			}
+
+		case p.tok.kind == tokID:
+			keyTok := p.tok
+			p.advance()
+
+			if err := p.port(); err != nil {
+				return nil, err
+			}
+
+			p.node(keyTok, sc)
+			nodes = []string{keyTok.text}
+
+		default:
+			return nil, p.unexpected("node or subgraph")
+		}
+
+		endpoints = append(endpoints, nodes)
+		members = append(members, nodes...)
+	}
+
+	a := sc.edge
+	if p.tok.kind == tokLBracket {
+		stmtAttrs, err := p.attrList()
+		if err != nil {
+			return nil, err
+		}
+
+		a = merge(a, stmtAttrs)
+	}
+
+	// an edge between each pair of consecutive endpoints; subgraphs stand
+	// for all of their nodes, and edges of undirected graphs become a
+	// channel for each direction
+	for i := 0; i+1 < len(endpoints); i++ {
+		for _, src := range endpoints[i] {
+			for _, dst := range endpoints[i+1] {
+				p.edges = append(p.edges, fileEdge{src, dst, a, line, col})
+
+				if !p.directed {
+					p.edges = append(p.edges, fileEdge{dst, src, a, line, col})
+				}
+			}
+		}
+	}
+
+	return members, nil
+}
+
+// attr_list : '[' [ a_list ] ']' [ attr_list ]
+// a_list    : ID '=' ID [ ( ';' | ',' ) ] [ a_list ]
+func (p *dotParser) attrList() (attrs, error) {
+	a := attrs{}
+
+	if p.tok.kind != tokLBracket {
+		return nil, p.unexpected("'['")
+	}
+
+	for p.tok.kind == tokLBracket {
+		p.advance()
+
+		for p.tok.kind != tokRBracket {
+			key := p.tok.text
+
+			if err := p.expect(tokID, "attribute name"); err != nil {
+				return nil, err
+			}
+
+			if err := p.expect(tokEqual, "'='"); err != nil {
+				return nil, err
+			}
+
+			a[key] = attr{p.tok.text, p.tok.line, p.tok.col}
+
+			if err := p.expect(tokID, "attribute value"); err != nil {
+				return nil, err
+			}
+
+			if p.tok.kind == tokComma || p.tok.kind == tokSemicolon {
+				p.advance()
+			}
+		}
+
+		p.advance()
+	}
+
+	return a, nil
+}
+
+// returns the node whose DOT ID is the given token, creating it with the
+// default attributes of the scope if it does not exist yet
+func (p *dotParser) node(t token, sc *scope) *fileNode {
+	if n, ok := p.nodes[t.text]; ok {
+		return n
+	}
+
+	n := &fileNode{key: t.text, attrs: sc.node.clone(), line: t.line, col: t.col}
+	p.nodes[t.text] = n
+	p.order = append(p.order, n)
+
+	return n
+}
+
+// Reads the parameters of a node written by older versions of the program in a
+// comment on the same line of the node statement:
+//
+//	// <sendText> <sendInterval> <relayMode> <paused> <x> <y> [<ttl>]
+//
+// Comments which do not start with a quoted string are ignored.
+func (p *dotParser) legacyComment(n *fileNode) *ParseError {
+	var c *comment
+
+	for i := range p.tok.comments {
+		if p.tok.comments[i].line == p.prevLine {
+			c = &p.tok.comments[i]
+		}
+	}
+
+	if c == nil || !strings.HasPrefix(c.text, "\"") {
+		return nil
+	}
+
+	// the comment is split in tokens by the DOT lexer; positions are
+	// relative to the comment, we move them to the file
+	lex := newLexer(c.text)
+
+	var values []token
+
+	for {
+		t, err := lex.next()
+		if err != nil {
+			perr := err.(*ParseError)
+			perr.Line = c.line
+			perr.Col += c.col + 2 // after the //
+
+			return perr
+		}
+
+		if t.kind == tokEOF {
+			break
+		}
+
+		t.line = c.line
+		t.col += c.col + 2
+
+		values = append(values, t)
+	}
+
+	p.explicit = true
+
+	if len(values) < 6 || len(values) > 7 || !values[0].quoted {
+		return &ParseError{
+			Line:     c.line,
+			Col:      c.col,
+			Token:    strconv.Quote("//" + c.text),
+			Expected: "<sendText> <sendInterval> <relayMode> <paused> <x> <y> [<ttl>]",
+		}
+	}
+
+	set := func(key string, t token) {
+		n.attrs[key] = attr{t.text, t.line, t.col}
+	}
+
+	set("send_text", values[0])
+	set("interval_ms", values[1])
+	set("relay", values[2])
+	set("paused", values[3])
+
+	// the position is in the coordinates of the program, the pos attribute
+	// in those of Graphviz, where y grows upwards
+	y, err := strconv.Atoi(values[5].text)
+	if err != nil {
+		return &ParseError{
+			Line:     values[5].line,
+			Col:      values[5].col,
+			Token:    values[5].String(),
+			Expected: "integer for <y>",
+		}
+	}
+
+	pos := values[4]
+	pos.text = values[4].text + "," + strconv.Itoa(-y)
+	set("pos", pos)
+
+	if len(values) == 7 {
+		set("ttl", values[6])
+	}
+
+	return nil
+}
@@ -0,0 +1,174 @@
+package engine
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// This file implements the codecs of formats which are only written, for other
+// tools: GraphML, read by graph analysis software, and Mermaid, rendered by
+// many wikis and documentation sites. Neither can be loaded back.
+
+type graphmlCodec struct{}
+
+// the data attached to nodes and edges, declared at the beginning of the file
+// as GraphML keys: id (which is also the name), element, type
+var GRAPHML_KEYS = [][3]string{
+	{"label", "node", "string"},
+	{"send_text", "node", "string"},
+	{"interval_ms", "node", "long"},
+	{"relay", "node", "string"},
+	{"paused", "node", "boolean"},
+	{"ttl", "node", "int"},
+	{"x", "node", "int"},
+	{"y", "node", "int"},
+
+	{"buf_size", "edge", "int"},
+	{"delay_ms", "edge", "long"},
+	{"jitter_ms", "edge", "long"},
+	{"loss", "edge", "double"},
+	{"overflow", "edge", "string"},
+	{"timeout_ms", "edge", "long"},
+}
+
+// Writes a directed GraphML graph, where all the parameters of nodes and
+// channels are data elements with the names of the DOT attributes. Positions
+// are in the coordinates of the program.
+func (graphmlCodec) encode(w io.Writer, nodes []nodespec, chans []chanspec) error {
+	esc := func(s string) string {
+		var b strings.Builder
+		xml.EscapeText(&b, []byte(s))
+		return b.String()
+	}
+
+	fmt.Fprintln(w, `<?xml version="1.0" encoding="UTF-8"?>`)
+	fmt.Fprintln(w, `<graphml xmlns="http://graphml.graphdrawing.org/xmlns"`)
+	fmt.Fprintln(w, `    xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance"`)
+	fmt.Fprintln(w, `    xsi:schemaLocation="http://graphml.graphdrawing.org/xmlns http://graphml.graphdrawing.org/xmlns/1.0/graphml.xsd">`)
+
+	for _, k := range GRAPHML_KEYS {
+		fmt.Fprintf(w, "  <key id=%q for=%q attr.name=%q attr.type=%q/>\n",
+			k[0], k[1], k[0], k[2])
+	}
+
+	fmt.Fprintln(w, `  <graph id="network" edgedefault="directed">`)
+
+	for _, n := range nodes {
+		fmt.Fprintf(w, "    <node id=\"n%d\">\n", n.id)
+
+		data := [][2]string{
+			{"label", esc(n.name)},
+			{"send_text", esc(n.sendText)},
+			{"interval_ms", strconv.FormatInt(n.sendInterval.Milliseconds(), 10)},
+			{"relay", n.relayMode.String()},
+			{"paused", strconv.FormatBool(n.paused)},
+			{"ttl", strconv.Itoa(n.ttl)},
+			{"x", strconv.Itoa(n.x)},
+			{"y", strconv.Itoa(n.y)},
+		}
+
+		for _, d := range data {
+			fmt.Fprintf(w, "      <data key=%q>%s</data>\n", d[0], d[1])
+		}
+
+		fmt.Fprintln(w, "    </node>")
+	}
+
+	for i, c := range chans {
+		fmt.Fprintf(w, "    <edge id=\"e%d\" source=\"n%d\" target=\"n%d\">\n",
+			i, c.src, c.info.Dst)
+
+		data := [][2]string{
+			{"buf_size", strconv.Itoa(c.info.Link.BufSize)},
+			{"delay_ms", strconv.FormatInt(c.info.Link.Delay.Milliseconds(), 10)},
+			{"jitter_ms", strconv.FormatInt(c.info.Link.Jitter.Milliseconds(), 10)},
+			{"loss", strconv.FormatFloat(c.info.Link.Loss, 'g', -1, 64)},
+			{"overflow", c.info.Overflow.String()},
+			{"timeout_ms", strconv.FormatInt(c.info.Timeout.Milliseconds(), 10)},
+		}
+
+		for _, d := range data {
+			fmt.Fprintf(w, "      <data key=%q>%s</data>\n", d[0], d[1])
+		}
+
+		fmt.Fprintln(w, "    </edge>")
+	}
+
+	fmt.Fprintln(w, "  </graph>")
+	_, err := fmt.Fprintln(w, "</graphml>")
+
+	return err
+}
+
+func (graphmlCodec) decode(r io.Reader) ([]nodespec, []chanspec, error) {
+	return nil, nil, errors.New("GraphML files can only be written")
+}
+
+type mermaidCodec struct{}
+
+// style of the nodes with each relay mode, and of the paused ones
+var MERMAID_CLASSES = [][2]string{
+	{"round_robin", "fill:#dbeafe,stroke:#1f77b4"},
+	{"multicast", "fill:#ffedd5,stroke:#ff7f0e"},
+	{"discard", "fill:#e5e7eb,stroke:#6b7280"},
+	{"paused", "stroke-dasharray:5 5"},
+}
+
+// Escapes a string for a quoted Mermaid label. Mermaid reads #name; and #123;
+// as entities, so # and the characters with a special meaning are written as
+// entities; newlines become line breaks.
+func mermaidEscape(s string) string {
+	return strings.NewReplacer(
+		"#", "#35;",
+		`"`, "#quot;",
+		"<", "#lt;",
+		">", "#gt;",
+		"\n", "<br>",
+	).Replace(s)
+}
+
+// Writes a Mermaid flowchart. Each node is labeled with its name and has the
+// class of its relay mode (and the paused class, if it is paused); channels
+// are labeled with their parameters which differ from the defaults. Positions
+// are not written, Mermaid places the nodes by itself.
+func (mermaidCodec) encode(w io.Writer, nodes []nodespec, chans []chanspec) error {
+	fmt.Fprintln(w, "flowchart LR")
+
+	var paused []string
+
+	for _, n := range nodes {
+		fmt.Fprintf(w, "    n%d[\"%s\"]:::%v\n", n.id, mermaidEscape(n.name), n.relayMode)
+
+		if n.paused {
+			paused = append(paused, "n"+strconv.Itoa(int(n.id)))
+		}
+	}
+
+	for _, c := range chans {
+		if params := c.info.params(); len(params) > 0 {
+			fmt.Fprintf(w, "    n%d -->|\"%s\"| n%d\n",
+				c.src, strings.Join(params, ", "), c.info.Dst)
+		} else {
+			fmt.Fprintf(w, "    n%d --> n%d\n", c.src, c.info.Dst)
+		}
+	}
+
+	if len(paused) > 0 {
+		fmt.Fprintf(w, "    class %s paused\n", strings.Join(paused, ","))
+	}
+
+	var err error
+	for _, class := range MERMAID_CLASSES {
+		_, err = fmt.Fprintf(w, "    classDef %s %s\n", class[0], class[1])
+	}
+
+	return err
+}
+
+func (mermaidCodec) decode(r io.Reader) ([]nodespec, []chanspec, error) {
+	return nil, nil, errors.New("Mermaid files can only be written")
+}
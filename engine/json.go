@@ -0,0 +1,418 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"unicode/utf8"
+)
+
+// This file implements the JSON codec. A network is stored as an object with
+// the version of the format, the nodes and the channels:
+//
+//	{
+//	  "format_version": 2,
+//	  "nodes": [
+//	    {"id": 0, "name": "node", "send_text": "from 0", "interval_ms": 1000,
+//	     "relay": "round_robin", "paused": false, "ttl": 0, "x": 100, "y": 200}
+//	  ],
+//	  "channels": [
+//	    {"src": 0, "dst": 1, "buf_size": 128, "delay_ms": 0, "jitter_ms": 0,
+//	     "loss": 0, "overflow": "block", "timeout_ms": 0}
+//	  ]
+//	}
+//
+// The fields of nodes and channels have the names and values of the DOT
+// attributes, and are checked in the same way; only the positions are stored
+// in the coordinates of the program (y grows downwards), as x and y. When
+// reading, unknown fields are ignored and missing ones take their default
+// values, except for the IDs and the endpoints of the channels.
+
+type jsonNetwork struct {
+	FormatVersion int        `json:"format_version"`
+	Nodes         []jsonNode `json:"nodes"`
+	Channels      []jsonChan `json:"channels"`
+}
+
+type jsonNode struct {
+	ID         NodeID `json:"id"`
+	Name       string `json:"name"`
+	SendText   string `json:"send_text"`
+	IntervalMs int64  `json:"interval_ms"`
+	Relay      string `json:"relay"`
+	Paused     bool   `json:"paused"`
+	TTL        int    `json:"ttl"`
+	X          int    `json:"x"`
+	Y          int    `json:"y"`
+}
+
+type jsonChan struct {
+	Src       NodeID  `json:"src"`
+	Dst       NodeID  `json:"dst"`
+	BufSize   int     `json:"buf_size"`
+	DelayMs   int64   `json:"delay_ms"`
+	JitterMs  int64   `json:"jitter_ms"`
+	Loss      float64 `json:"loss"`
+	Overflow  string  `json:"overflow"`
+	TimeoutMs int64   `json:"timeout_ms"`
+}
+
+type jsonCodec struct{}
+
+// writes the network indented, all the fields are always written
+func (jsonCodec) encode(w io.Writer, nodes []nodespec, chans []chanspec) error {
+	jn := jsonNetwork{
+		FormatVersion: FORMAT_VERSION,
+		Nodes:         make([]jsonNode, 0, len(nodes)),
+		Channels:      make([]jsonChan, 0, len(chans)),
+	}
+
+	for _, n := range nodes {
+		jn.Nodes = append(jn.Nodes, jsonNode{
+			ID:         n.id,
+			Name:       n.name,
+			SendText:   n.sendText,
+			IntervalMs: n.sendInterval.Milliseconds(),
+			Relay:      n.relayMode.String(),
+			Paused:     n.paused,
+			TTL:        n.ttl,
+			X:          n.x,
+			Y:          n.y,
+		})
+	}
+
+	for _, c := range chans {
+		jn.Channels = append(jn.Channels, jsonChan{
+			Src:       c.src,
+			Dst:       c.info.Dst,
+			BufSize:   c.info.Link.BufSize,
+			DelayMs:   c.info.Link.Delay.Milliseconds(),
+			JitterMs:  c.info.Link.Jitter.Milliseconds(),
+			Loss:      c.info.Link.Loss,
+			Overflow:  c.info.Overflow.String(),
+			TimeoutMs: c.info.Timeout.Milliseconds(),
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+
+	return enc.Encode(jn)
+}
+
+// Reads the network. The nodes and channels are read one by one, to know their
+// positions in the file for the error messages, and turned into the same graph
+// built by the DOT parser, whose attributes are checked in the same way.
+func (jsonCodec) decode(r io.Reader) ([]nodespec, []chanspec, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	d := &jsonDecoder{
+		src:       src,
+		dec:       json.NewDecoder(bytes.NewReader(src)),
+		fileGraph: newFileGraph(),
+	}
+
+	// nodes are never created by the channels
+	d.explicit = true
+
+	if err := d.network(); err != nil {
+		if err != errTooManyErrors {
+			d.addError(err.(*ParseError))
+		}
+
+		// the rest of the file has not been read, checking the
+		// channels would only find missing nodes
+		return nil, nil, d.errs
+	}
+
+	return d.result()
+}
+
+type jsonDecoder struct {
+	src []byte
+	dec *json.Decoder
+
+	// the graph read so far
+	*fileGraph
+}
+
+// Returns the line and column of the value which starts at the given offset
+// in the file, or after it: the offsets returned by the decoder can be before
+// the spaces and separators which precede the value.
+func (d *jsonDecoder) pos(off int64) (int, int) {
+	for int(off) < len(d.src) && bytes.IndexByte([]byte(" \t\r\n,:"), d.src[off]) >= 0 {
+		off++
+	}
+
+	line := 1 + bytes.Count(d.src[:off], []byte("\n"))
+	start := bytes.LastIndexByte(d.src[:off], '\n') + 1
+	col := 1 + utf8.RuneCount(d.src[start:off])
+
+	return line, col
+}
+
+// Decodes the next value into v. Returns the errors with their position;
+// values of the wrong type are skipped, only syntax errors stop the decoder.
+func (d *jsonDecoder) decode(v interface{}, expected string) error {
+	line, col := d.pos(d.dec.InputOffset())
+
+	err := d.dec.Decode(v)
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return d.addError(&ParseError{
+			Line:     line,
+			Col:      col,
+			Token:    typeErr.Value,
+			Expected: expected,
+		})
+	}
+
+	if err != nil {
+		return d.syntaxError(err)
+	}
+
+	return nil
+}
+
+// returns the ParseError for an error of the decoder, which is not recoverable
+func (d *jsonDecoder) syntaxError(err error) *ParseError {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		// the offset is after the invalid character
+		line, col := d.pos(max(0, syntaxErr.Offset-1))
+		return &ParseError{Line: line, Col: col, Msg: syntaxErr.Error()}
+	}
+
+	line, col := d.pos(int64(len(d.src)))
+
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return &ParseError{Line: line, Col: col, Msg: "unexpected end of file"}
+	}
+
+	return &ParseError{Line: line, Col: col, Msg: err.Error()}
+}
+
+// reads the delimiter c, described by what for the errors
+func (d *jsonDecoder) delim(c json.Delim, what string) error {
+	line, col := d.pos(d.dec.InputOffset())
+
+	t, err := d.dec.Token()
+	if err != nil {
+		return d.syntaxError(err)
+	}
+
+	if t != c {
+		found := fmt.Sprint(t)
+
+		switch t := t.(type) {
+		case json.Delim:
+			found = "'" + t.String() + "'"
+		case string:
+			found = strconv.Quote(t)
+		}
+
+		return &ParseError{Line: line, Col: col, Token: found, Expected: what}
+	}
+
+	return nil
+}
+
+// reads the top-level object
+func (d *jsonDecoder) network() error {
+	if err := d.delim('{', "'{'"); err != nil {
+		return err
+	}
+
+	for d.dec.More() {
+		t, err := d.dec.Token()
+		if err != nil {
+			return d.syntaxError(err)
+		}
+
+		var raw json.RawMessage
+
+		switch t {
+		case "format_version":
+			line, col := d.pos(d.dec.InputOffset())
+
+			if err := d.decode(&raw, "format version"); err != nil {
+				return err
+			}
+
+			d.graphAttrs["format_version"] = attr{jsonValue(raw), line, col}
+
+		case "nodes":
+			err = d.array(d.node)
+
+		case "channels":
+			err = d.array(d.channel)
+
+		default:
+			// unknown fields are ignored
+			err = d.decode(&raw, "value")
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := d.delim('}', "'}'"); err != nil {
+		return err
+	}
+
+	if _, err := d.dec.Token(); err != io.EOF {
+		line, col := d.pos(d.dec.InputOffset())
+		return &ParseError{Line: line, Col: col, Token: "more data", Expected: "end of file"}
+	}
+
+	return nil
+}
+
+// reads an array of objects, calling f on each with its position
+func (d *jsonDecoder) array(f func(obj map[string]json.RawMessage, line, col int) error) error {
+	if err := d.delim('[', "'['"); err != nil {
+		return err
+	}
+
+	for d.dec.More() {
+		line, col := d.pos(d.dec.InputOffset())
+
+		var obj map[string]json.RawMessage
+
+		n := len(d.errs)
+		if err := d.decode(&obj, "object"); err != nil {
+			return err
+		}
+
+		if len(d.errs) > n {
+			// not an object, the error has been recorded
+			continue
+		}
+
+		if err := f(obj, line, col); err != nil {
+			return err
+		}
+	}
+
+	return d.delim(']', "']'")
+}
+
+// Returns the value of a field as a DOT attribute would be written: strings
+// without quotes, other values as they are.
+func jsonValue(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+
+	return string(raw)
+}
+
+// Reads a field which holds a node ID: the ID of a node or an endpoint of a
+// channel. Returns the key of the node, which is the ID as a string, or false
+// if the field is missing or invalid (in which case the error is recorded).
+func (d *jsonDecoder) nodeKey(obj map[string]json.RawMessage, field string,
+	line, col int) (string, bool, error) {
+
+	raw, ok := obj[field]
+	if !ok {
+		err := &ParseError{Line: line, Col: col, Msg: "missing field " + strconv.Quote(field)}
+		return "", false, d.addError(err)
+	}
+
+	a := attr{jsonValue(raw), line, col}
+
+	id, err := strconv.Atoi(a.value)
+	if err != nil || id < 0 {
+		return "", false, d.addError(attrError(field, a, "non-negative integer"))
+	}
+
+	return strconv.Itoa(id), true, nil
+}
+
+func (d *jsonDecoder) node(obj map[string]json.RawMessage, line, col int) error {
+	key, ok, err := d.nodeKey(obj, "id", line, col)
+	if !ok {
+		return err
+	}
+
+	if n, ok := d.nodes[key]; ok {
+		return d.addError(&ParseError{
+			Line:  line,
+			Col:   col,
+			Token: key,
+			Msg:   fmt.Sprintf("node %q is already defined at line %d", key, n.line),
+		})
+	}
+
+	a := attrs{}
+	for k, raw := range obj {
+		a[k] = attr{jsonValue(raw), line, col}
+	}
+
+	// the position is turned into the pos attribute of Graphviz, where y
+	// grows upwards
+	x, okX := a["x"]
+	y, okY := a["y"]
+
+	if okX || okY {
+		xv, errX := strconv.Atoi(x.value)
+		yv, errY := strconv.Atoi(y.value)
+
+		switch {
+		case !okX || !okY:
+			missing := "x"
+			if okX {
+				missing = "y"
+			}
+
+			err = d.addError(&ParseError{
+				Line: line,
+				Col:  col,
+				Msg:  fmt.Sprintf("missing field %q, x and y go together", missing),
+			})
+		case errX != nil:
+			err = d.addError(attrError("x", attr{x.value, line, col}, "integer"))
+		case errY != nil:
+			err = d.addError(attrError("y", attr{y.value, line, col}, "integer"))
+		default:
+			a["pos"] = attr{fmt.Sprintf("%d,%d", xv, -yv), line, col}
+		}
+	}
+
+	n := &fileNode{key: key, attrs: a, line: line, col: col, declared: true, defLine: line}
+	d.nodes[key] = n
+	d.order = append(d.order, n)
+
+	return err
+}
+
+func (d *jsonDecoder) channel(obj map[string]json.RawMessage, line, col int) error {
+	src, ok, err := d.nodeKey(obj, "src", line, col)
+	if !ok {
+		return err
+	}
+
+	dst, ok, err := d.nodeKey(obj, "dst", line, col)
+	if !ok {
+		return err
+	}
+
+	a := attrs{}
+	for k, raw := range obj {
+		a[k] = attr{jsonValue(raw), line, col}
+	}
+
+	d.edges = append(d.edges, fileEdge{src, dst, a, line, col})
+
+	return nil
+}
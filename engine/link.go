@@ -0,0 +1,151 @@
+package engine
+
+import (
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+)
+
+// Every channel between two nodes simulates a link with its own buffer size,
+// delivery delay and loss probability. A channel is made of a buffered Go
+// channel, on which the source node sends its messages, and of a link goroutine
+// which takes the messages from it one at a time and delivers them to the input
+// channel of the destination after the delay, unless they are lost. The message
+// held by the link goroutine counts towards the buffer size, so that the channel
+// is full when BufSize messages are waiting.
+//
+// The source node is the only one that sends on the channel, so it is also the
+// one that closes it when the channel is deleted; the link goroutine still
+// delivers the messages left in the buffer, then terminates.
+
+// parameters of the link simulated by a channel
+type LinkParams struct {
+	// capacity of the channel, including the message being delivered;
+	// unbuffered channels (0) still carry one message at a time, like
+	// channels of size 1
+	BufSize int
+
+	Delay  time.Duration // fixed delivery delay
+	Jitter time.Duration // random extra delay, uniform in [0, Jitter)
+	Loss   float64       // probability that a message is lost, in [0, 1]
+}
+
+// parameters of new channels: instant and reliable delivery, CHAN_BUF_SIZE
+// buffer
+var DEFAULT_LINK = LinkParams{BufSize: CHAN_BUF_SIZE}
+
+// state of a channel shared by the main goroutine, the source node and the link
+// goroutine
+type link struct {
+	ch datachan
+
+	// true while the link goroutine holds a message taken from ch, which
+	// is still waiting to be delivered
+	holding atomic.Bool
+
+	// the parameters other than BufSize can be changed while the link
+	// is running, so main replaces them atomically
+	params atomic.Pointer[LinkParams]
+}
+
+// creates the channel from src to dst and spawns its link goroutine
+func (net *Network) newLink(src NodeID, dst NodeID, p LinkParams) *link {
+	// the link goroutine holds one more message
+	l := &link{ch: make(datachan, max(p.BufSize-1, 0))}
+	l.params.Store(&p)
+
+	go linkMain(l, src, net.nodes[src].quit, dst, net.nodes[dst].in,
+		net.nodes[dst].quit, net.dropChan)
+
+	return l
+}
+
+// returns the number of messages waiting in the channel, including the one
+// being delivered
+func (l *link) queued() int {
+	n := len(l.ch)
+	if l.holding.Load() {
+		n++
+	}
+
+	return n
+}
+
+// The code executed by link goroutines. Messages are taken from the channel one
+// at a time and held until their delivery time. The delay is counted from when
+// the source sent each message, so the messages waiting in the buffer age in
+// the meantime and the delay does not limit the throughput of the link.
+func linkMain(
+	l *link,
+	src NodeID,
+	srcQuit chan struct{},
+	dst NodeID,
+	dstIn datachan,
+	dstQuit chan struct{},
+	dropChan chan DropReport,
+) {
+	// delivery time of the previous message: messages are delivered in
+	// order, the jitter can only delay them further
+	var last time.Time
+
+	for {
+		// the link stops when either endpoint quits
+		var m Message
+		var ok bool
+
+		select {
+		case m, ok = <-l.ch:
+			if !ok {
+				// channel closed and all messages delivered
+				return
+			}
+
+		case <-srcQuit:
+			return
+
+		case <-dstQuit:
+			return
+		}
+
+		p := l.params.Load()
+
+		if p.Loss > 0 && rand.Float64() < p.Loss {
+			dropChan <- DropReport{Node: src, Dst: dst, Msg: m, Reason: LOST}
+			continue
+		}
+
+		l.holding.Store(true)
+
+		delay := p.Delay
+		if p.Jitter > 0 {
+			delay += time.Duration(rand.Int64N(int64(p.Jitter)))
+		}
+
+		due := m.sent.Add(delay)
+		if due.Before(last) {
+			due = last
+		}
+		last = due
+
+		timer := time.NewTimer(time.Until(due))
+
+		select {
+		case <-timer.C:
+		case <-srcQuit:
+			timer.Stop()
+			return
+		case <-dstQuit:
+			timer.Stop()
+			return
+		}
+
+		select {
+		case dstIn <- m:
+			l.holding.Store(false)
+		case <-srcQuit:
+			return
+		case <-dstQuit:
+			return
+		}
+	}
+}
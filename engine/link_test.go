@@ -0,0 +1,36 @@
+package engine
+
+import (
+	"testing"
+	"time"
+)
+
+// the messages held by the link count towards the buffer size, so a paused
+// destination makes the channel full after BufSize messages
+func TestLinkCapacity(t *testing.T) {
+	for _, size := range []int{1, 4, 16} {
+		net := New()
+
+		src, dst := net.Spawn(0, 0), net.Spawn(0, 0)
+		net.SetSendInterval(dst, 0)
+		net.TogglePause(dst)
+
+		net.Connect(src, dst)
+		net.SetLink(src, dst, LinkParams{BufSize: size, Delay: 10 * time.Millisecond})
+		net.SetOverflow(src, dst, DROP_NEWEST, 0)
+		net.SetSendInterval(src, time.Millisecond)
+
+		time.Sleep(100 * time.Millisecond)
+		net.Poll()
+
+		n, _ := net.Node(src)
+		c := n.Outs[0]
+
+		if c.Sent != size || c.Queued() != size || c.Dropped == 0 {
+			t.Errorf("buffer size %d: %d messages sent, %d queued, %d dropped",
+				size, c.Sent, c.Queued(), c.Dropped)
+		}
+
+		net.StopAllAndWait()
+	}
+}
@@ -0,0 +1,654 @@
+// Package engine implements the runtime of the network manager: every node of
+// the network runs in its own goroutine, and nodes exchange messages over Go
+// channels.
+//
+// A Network keeps track of the running nodes and of the channels between them,
+// and it is the only way to spawn, connect, configure and stop nodes. It is
+// independent of the graphical interface, so networks can also be run
+// headless (e.g. from tests or from the command line).
+//
+// The methods of Network are not safe for concurrent use: they are meant to be
+// called from a single goroutine (the "main goroutine" in the comments), which
+// must also call Poll regularly.
+package engine
+
+import (
+	"log"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// each node has a fixed unique numeric id assigned at creation
+type NodeID int
+
+// how received messages should be retrasmitted
+type RelayMode int
+
+const (
+	ROUND_ROBIN RelayMode = iota
+	MULTICAST
+	DISCARD
+)
+
+func (m RelayMode) String() string {
+	switch m {
+	case ROUND_ROBIN:
+		return "round_robin"
+	case MULTICAST:
+		return "multicast"
+	case DISCARD:
+		return "discard"
+	default:
+		return "unknown"
+	}
+}
+
+// Inverse of RelayMode.String. The numeric values of the modes are also
+// accepted, as they are used by files written by older versions of the
+// program. The second return value is false if the mode is not valid.
+func ParseRelayMode(s string) (RelayMode, bool) {
+	for m := ROUND_ROBIN; m <= DISCARD; m++ {
+		if m.String() == s || strconv.Itoa(int(m)) == s {
+			return m, true
+		}
+	}
+
+	return ROUND_ROBIN, false
+}
+
+// what a node does when it has to send a message on a full channel
+type OverflowPolicy int
+
+const (
+	BLOCK         OverflowPolicy = iota // wait until there is room
+	DROP_NEWEST                         // drop the message being sent
+	DROP_OLDEST                         // drop the oldest message in the channel
+	BLOCK_TIMEOUT                       // wait, but drop the message after a timeout
+)
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case BLOCK:
+		return "block"
+	case DROP_NEWEST:
+		return "drop_newest"
+	case DROP_OLDEST:
+		return "drop_oldest"
+	case BLOCK_TIMEOUT:
+		return "block_timeout"
+	default:
+		return "unknown"
+	}
+}
+
+// inverse of OverflowPolicy.String, the second return value is false if the
+// name is not valid
+func ParseOverflowPolicy(s string) (OverflowPolicy, bool) {
+	for p := BLOCK; p <= BLOCK_TIMEOUT; p++ {
+		if p.String() == s {
+			return p, true
+		}
+	}
+
+	return BLOCK, false
+}
+
+// Information kept by main about each channel.
+// It corresponds to nodeout (see node.go), which stores the information kept
+// by the source nodes about the same channels.
+type ChanInfo struct {
+	Dst NodeID
+
+	// what the source does when the channel is full; Timeout is only used
+	// by the BLOCK_TIMEOUT policy
+	Overflow OverflowPolicy
+	Timeout  time.Duration
+
+	// buffer size, delay and loss of the channel, see link.go
+	Link LinkParams
+
+	// number of messages sent on the channel, number of messages dropped
+	// because the channel was full, and number of messages lost by the link
+	Sent    int
+	Dropped int
+	Lost    int
+
+	link *link
+}
+
+// returns the number of messages currently waiting in the channel buffer,
+// including the one which the link is delivering
+func (c ChanInfo) Queued() int {
+	// channels returned by ReadNodes have no link
+	if c.link == nil {
+		return 0
+	}
+
+	return c.link.queued()
+}
+
+// Information kept by the main goroutine about each node. A copy can be
+// obtained with Network.Node; changing it has no effect on the running node.
+type Node struct {
+	ID   NodeID
+	Name string // display name, used in the UI and text output
+
+	// each node has its own:
+	// - control channel, for receiving commands from the main goroutine
+	// - input channel, where the link goroutines of the channels towards
+	//   this node deliver their messages. Main needs to know it to create
+	//   new connections.
+	ctl ctlchan
+	in  datachan
+
+	// closed by main to tell the node to quit; since it can be closed only
+	// once, main does it through stop
+	quit chan struct{}
+	stop func()
+
+	// shared with the node goroutine, read by the watchdog
+	status *nodeStatus
+
+	// for each output channel of this node, a ChanInfo struct holds its
+	// destination
+	Outs []ChanInfo
+
+	// The text, interval and relay mode (round-robin, multicast, discard)
+	// of this node. Main needs to know those to show them in the node
+	// control panel.
+	SendText     string
+	SendInterval time.Duration
+	RelayMode    RelayMode
+
+	// maximum number of times the messages generated by this node can be
+	// relayed, 0 for no limit
+	TTL int
+
+	Paused bool
+
+	// number of messages dropped by this node because their TTL expired
+	Expired int
+
+	// coordinates of the node in world space, purely for the visualization
+	X, Y int
+}
+
+// Returns true if Stop has been called on the node. The node stays in the
+// network until its goroutine has terminated, but it should be treated as
+// deleted; SpawnWithID can already reuse its ID.
+func (n Node) Stopped() bool {
+	select {
+	case <-n.quit:
+		return true
+	default:
+		return false
+	}
+}
+
+// returns the number of messages sent by the node on its current channels
+func (n Node) Sent() int {
+	sent := 0
+	for _, o := range n.Outs {
+		sent += o.Sent
+	}
+
+	return sent
+}
+
+// returns the number of messages received by the node since it was spawned
+// (0 for the nodes returned by ReadNodes, which were never spawned)
+func (n Node) Received() int {
+	if n.status == nil {
+		return 0
+	}
+
+	return int(n.status.received.Load())
+}
+
+// struct sent on the reportChan after each send,
+// it contains source and destination nodes and a copy of the message
+type SendReport struct {
+	Src, Dst NodeID
+	Msg      Message
+}
+
+// why a message was dropped by a node
+type DropReason int
+
+const (
+	TTL_EXPIRED DropReason = iota
+	CHAN_FULL              // according to the overflow policy of the channel
+	LOST                   // according to the loss probability of the channel
+)
+
+// struct sent on the dropChan every time a node drops a message instead of
+// relaying or sending it
+type DropReport struct {
+	Node   NodeID
+	Dst    NodeID // destination of the channel, for CHAN_FULL and LOST
+	Msg    Message
+	Reason DropReason
+}
+
+// A running network of nodes. The zero value is not usable, networks must be
+// created with New.
+type Network struct {
+	nodes map[NodeID]Node
+
+	// ID assigned to the next node created with Spawn
+	nextID NodeID
+
+	stopChan   chan NodeID     // used by nodes to signal termination
+	reportChan chan SendReport // used by nodes to report every send
+	dropChan   chan DropReport // used by nodes to report dropped messages
+
+	// functions called by Poll for every send/drop report
+	subscribers     []func(SendReport)
+	dropSubscribers []func(DropReport)
+
+	// watchdog state, see watchdog.go
+	stallTimeout time.Duration
+	stalled      map[NodeID]NodeID
+	deadlocks    [][]NodeID
+
+	// order of the nodes in the files loaded with Deserialize and Merge,
+	// kept by Serialize if preserveOrder is set, see serializer.go
+	order         []NodeID
+	preserveOrder bool
+}
+
+// create a new, empty network
+func New() *Network {
+	return &Network{
+		nodes:      make(map[NodeID]Node),
+		stopChan:   make(chan NodeID, 32),
+		reportChan: make(chan SendReport, 1024),
+		dropChan:   make(chan DropReport, 1024),
+
+		stallTimeout: DEFAULT_STALL_TIMEOUT,
+	}
+}
+
+// Returns the nodes of the network, indexed by ID.
+// The map is owned by the network and must not be modified.
+func (net *Network) Nodes() map[NodeID]Node {
+	return net.nodes
+}
+
+// returns the node with the given ID
+// the second return value is false if there is no such node
+func (net *Network) Node(id NodeID) (Node, bool) {
+	n, ok := net.nodes[id]
+	return n, ok
+}
+
+// Registers a function which is called (from Poll) every time a node sends a
+// message on one of its output channels.
+func (net *Network) Subscribe(f func(SendReport)) {
+	net.subscribers = append(net.subscribers, f)
+}
+
+// Registers a function which is called (from Poll) every time a node drops a
+// message.
+func (net *Network) SubscribeDrops(f func(DropReport)) {
+	net.dropSubscribers = append(net.dropSubscribers, f)
+}
+
+// Handles the notifications sent by the nodes to the main goroutine: send and
+// drop reports are passed to the subscribers (and counted in the Node and
+// ChanInfo structs), and nodes which quit are removed from the network. It
+// also runs the watchdog, which looks for stalled nodes. Nodes block when the
+// notification buffers are full, so Poll should be called frequently (the UI
+// calls it on every update).
+func (net *Network) Poll() {
+loop1: // handle messages on reportChan
+	for {
+		select {
+		case r := <-net.reportChan:
+			for i, o := range net.nodes[r.Src].Outs {
+				if o.Dst == r.Dst {
+					net.nodes[r.Src].Outs[i].Sent++
+				}
+			}
+
+			for _, f := range net.subscribers {
+				f(r)
+			}
+
+		default:
+			break loop1
+		}
+	}
+
+loop2: // handle messages on dropChan
+	for {
+		select {
+		case r := <-net.dropChan:
+			if n, ok := net.nodes[r.Node]; ok {
+				switch r.Reason {
+				case TTL_EXPIRED:
+					n.Expired++
+
+				case CHAN_FULL:
+					for i := range n.Outs {
+						if n.Outs[i].Dst == r.Dst {
+							n.Outs[i].Dropped++
+						}
+					}
+
+				case LOST:
+					for i := range n.Outs {
+						if n.Outs[i].Dst == r.Dst {
+							n.Outs[i].Lost++
+						}
+					}
+				}
+
+				net.nodes[r.Node] = n
+			}
+
+			for _, f := range net.dropSubscribers {
+				f(r)
+			}
+
+		default:
+			break loop2
+		}
+	}
+
+loop3: // handle messages on stopChan
+	for {
+		select {
+		case i := <-net.stopChan:
+			// node i quit, we can delete it, unless it has been
+			// replaced by a new node with the same ID in the
+			// meantime (e.g. when a deletion is undone)
+			if n, ok := net.nodes[i]; ok && n.Stopped() {
+				delete(net.nodes, i)
+			}
+
+		default:
+			break loop3
+		}
+	}
+
+	net.watchdog()
+}
+
+// add a new node to the network, and spawn its goroutine
+// the ID is passed as a parameter (needed when loading a network from a file)
+func (net *Network) SpawnWithID(id NodeID, x, y int) {
+	// create node with some defaults
+	n := Node{
+		ID:   id,
+		Name: "node",
+
+		ctl: make(ctlchan, 16),
+		in:  make(datachan), // buffering is done by the channels
+
+		quit: make(chan struct{}),
+
+		status: newNodeStatus(),
+
+		SendText: "from " + strconv.Itoa(int(id)),
+
+		RelayMode: ROUND_ROBIN,
+
+		X: x,
+		Y: y,
+	}
+
+	n.stop = sync.OnceFunc(func() { close(n.quit) })
+
+	net.nodes[id] = n
+
+	// make sure that Spawn never reuses this ID
+	net.nextID = max(net.nextID, id+1)
+
+	// spawn node goroutine
+	go nodeMain(n, net.stopChan, net.reportChan, net.dropChan)
+}
+
+// same as SpawnWithID, but a fresh ID is chosen and returned
+func (net *Network) Spawn(x, y int) NodeID {
+	id := net.nextID
+	net.SpawnWithID(id, x, y)
+	return id
+}
+
+// utility function to send a control message to a node; stopped nodes are
+// skipped, since they no longer read their control channel and sending to
+// them would eventually block
+func (net *Network) sendCtl(dst NodeID, action ctlact, payload interface{}) {
+	n, ok := net.nodes[dst]
+	if !ok || n.Stopped() {
+		return
+	}
+
+	n.ctl <- ctlmsg{action: action, payload: payload}
+}
+
+// now follow some functions that change the parameters of a running node by
+// sending a control message, and also update the corresponding parameter in the
+// nodes map
+
+func (net *Network) SetName(id NodeID, name string) {
+	n := net.nodes[id]
+	n.Name = name
+	net.nodes[id] = n
+
+	net.sendCtl(id, SET_NAME, name)
+}
+
+// change the position of a node, which is only used for the visualization
+func (net *Network) Move(id NodeID, x, y int) {
+	n, ok := net.nodes[id]
+	if !ok {
+		return
+	}
+
+	n.X, n.Y = x, y
+	net.nodes[id] = n
+}
+
+// returns true if there is a channel from i to j
+func (net *Network) Connected(i NodeID, j NodeID) bool {
+	return slices.ContainsFunc(net.nodes[i].Outs, func(c ChanInfo) bool {
+		return c.Dst == j
+	})
+}
+
+// create a channel from i to j, if there is none yet
+func (net *Network) Connect(i NodeID, j NodeID) {
+	if net.Connected(i, j) {
+		return
+	}
+
+	c := ChanInfo{
+		Dst:  j,
+		Link: DEFAULT_LINK,
+		link: net.newLink(i, j, DEFAULT_LINK),
+	}
+
+	// add in nodes map
+	n := net.nodes[i]
+	n.Outs = append(n.Outs, c)
+	net.nodes[i] = n
+
+	// tell the node to add it too
+	net.sendCtl(i, ADD_DEST, net.nodeout(i, c))
+}
+
+// the nodeout struct corresponding to channel c from node i
+func (net *Network) nodeout(i NodeID, c ChanInfo) nodeout {
+	return nodeout{
+		ch:       c.link.ch,
+		dst:      c.Dst,
+		overflow: c.Overflow,
+		timeout:  c.Timeout,
+		dstQuit:  net.nodes[c.Dst].quit,
+	}
+}
+
+// delete the channel from i to j, if there is one
+func (net *Network) Disconnect(i NodeID, j NodeID) {
+	if !net.Connected(i, j) {
+		return
+	}
+
+	// delete from nodes map
+	n := net.nodes[i]
+	n.Outs = slices.DeleteFunc(n.Outs, func(c ChanInfo) bool {
+		return c.Dst == j
+	})
+	net.nodes[i] = n
+
+	// tell the node to delete it too
+	net.sendCtl(i, DEL_DEST, j)
+}
+
+// set what i does when the channel from i to j is full; the timeout is only
+// used by the BLOCK_TIMEOUT policy
+func (net *Network) SetOverflow(
+	i NodeID,
+	j NodeID,
+	policy OverflowPolicy,
+	timeout time.Duration,
+) {
+	n := net.nodes[i]
+	for k := range n.Outs {
+		if n.Outs[k].Dst == j {
+			n.Outs[k].Overflow = policy
+			n.Outs[k].Timeout = timeout
+		}
+	}
+	net.nodes[i] = n
+
+	net.sendCtl(i, SET_OVERFLOW,
+		nodeout{dst: j, overflow: policy, timeout: timeout})
+}
+
+// Sets buffer size, delay and loss of the channel from i to j. A new buffer size
+// can only be applied by replacing the channel: the messages in the old buffer
+// are still delivered, after the delay they had when they were sent.
+func (net *Network) SetLink(i NodeID, j NodeID, p LinkParams) {
+	n := net.nodes[i]
+	for k := range n.Outs {
+		c := &n.Outs[k]
+		if c.Dst != j {
+			continue
+		}
+
+		if p.BufSize != c.Link.BufSize {
+			c.link = net.newLink(i, j, p)
+
+			// the node replaces the old channel to j with the new one
+			net.sendCtl(i, ADD_DEST, net.nodeout(i, *c))
+		} else {
+			c.link.params.Store(&p)
+		}
+
+		c.Link = p
+	}
+	net.nodes[i] = n
+}
+
+// create a channel between i and j if there is none yet, delete it otherwise
+func (net *Network) AddOrDelChan(i NodeID, j NodeID) {
+	if net.Connected(i, j) {
+		net.Disconnect(i, j)
+	} else {
+		net.Connect(i, j)
+	}
+}
+
+func (net *Network) SetRelayMode(id NodeID, mode RelayMode) {
+	n := net.nodes[id]
+	n.RelayMode = mode
+	net.nodes[id] = n
+
+	net.sendCtl(id, SET_RELAY_MODE, mode)
+}
+
+func (net *Network) SetSendText(id NodeID, text string) {
+	n := net.nodes[id]
+	n.SendText = text
+	net.nodes[id] = n
+
+	net.sendCtl(id, SET_SEND_TEXT, text)
+}
+
+func (net *Network) SetSendInterval(id NodeID, d time.Duration) {
+	n := net.nodes[id]
+	n.SendInterval = d
+	net.nodes[id] = n
+
+	net.sendCtl(id, SET_SEND_INTERVAL, d)
+}
+
+// set the maximum number of relays for the messages generated by the node,
+// 0 for no limit
+func (net *Network) SetTTL(id NodeID, ttl int) {
+	n := net.nodes[id]
+	n.TTL = ttl
+	net.nodes[id] = n
+
+	net.sendCtl(id, SET_TTL, ttl)
+}
+
+func (net *Network) TogglePause(id NodeID) bool {
+	n := net.nodes[id]
+	n.Paused = !n.Paused
+	net.nodes[id] = n
+
+	net.sendCtl(id, TOGGLE_PAUSE, nil)
+
+	return n.Paused
+}
+
+// Tells a node to quit. The node is removed from the network by Poll once its
+// goroutine has terminated; channels towards it are deleted right away, so
+// that no other node keeps sending to it.
+func (net *Network) Stop(id NodeID) {
+	for src := range net.nodes {
+		net.Disconnect(src, id)
+	}
+
+	if n, ok := net.nodes[id]; ok {
+		n.stop()
+	}
+}
+
+// Tells all nodes to quit and waits for their termination.
+// Afterwards the network is empty, and IDs are assigned starting from 0 again.
+func (net *Network) StopAllAndWait() {
+	log.Printf("[manager] stopping all nodes")
+
+	for _, n := range net.nodes {
+		n.stop()
+	}
+
+	// delete all nodes, not necessarily in the order we receive their
+	// quit notifications; we just need to make sure that they all
+	// terminated before proceeding
+	for i := range net.nodes {
+		// nodes may block while reporting their last sends or drops,
+		// keep draining reportChan and dropChan
+	wait:
+		for {
+			select {
+			case <-net.stopChan:
+				break wait
+			case <-net.reportChan:
+			case <-net.dropChan:
+			}
+		}
+
+		delete(net.nodes, i)
+	}
+
+	net.nextID = 0
+	net.order = nil
+}
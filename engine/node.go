@@ -0,0 +1,438 @@
+package engine
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"time"
+)
+
+// type of the channels where instructions are sent to nodes from the main goroutine
+type ctlchan chan ctlmsg
+
+// type of the channels between nodes
+type datachan chan Message
+
+// Messages exchanged between nodes. Other than the text, they carry some
+// metadata which allows to trace where a message came from and how long it
+// took to arrive.
+type Message struct {
+	Text string
+
+	Origin  NodeID    // node which generated the message
+	Seq     int       // sequence number, counted separately by each origin
+	Created time.Time // when the message was generated
+
+	// when the message was sent on the channel it is currently travelling
+	// on, used by the link to compute the delivery time
+	sent time.Time
+
+	// number of times the message has been relayed; 0 for messages
+	// received straight from their origin
+	Hops int
+
+	// Time to live: maximum number of times the message can be relayed,
+	// after which it is dropped instead (i.e. once Hops would exceed it).
+	// Messages generated with TTL 0 are never dropped.
+	TTL int
+}
+
+// format used in the log
+func (m Message) String() string {
+	return fmt.Sprintf("%q (origin %v, seq %d, %d hops, ttl %d)",
+		m.Text, m.Origin, m.Seq, m.Hops, m.TTL)
+}
+
+// default buffer size for channels between nodes
+const CHAN_BUF_SIZE = 128
+
+// messages on ctlchans are composed of a tag, which specifies the action the
+// receiving node should perform, and a payload which is action-dependent
+type ctlmsg struct {
+	action  ctlact
+	payload interface{}
+}
+
+type ctlact int
+
+const (
+	// set node display name
+	SET_NAME ctlact = iota
+
+	// add/remove a channel
+	ADD_DEST
+	DEL_DEST
+
+	// how received messages should be retrasmitted:
+	// round-robin, multicast, no relay
+	SET_RELAY_MODE
+
+	// for messages generated by this node, what should be the text
+	// and how frequently should they be sent out
+	SET_SEND_TEXT
+	SET_SEND_INTERVAL
+
+	// maximum number of times messages generated by this node can be
+	// relayed (0 for no limit)
+	SET_TTL
+
+	// what to do when sending on a full output channel
+	SET_OVERFLOW
+
+	TOGGLE_PAUSE
+)
+
+// Information kept by the nodes about their outgoing channels. Other than the
+// channel itself, we need the id of the destination for the purpose of
+// printing and reporting.
+// It corresponds to ChanInfo (see network.go), which stores the information
+// kept by the main goroutine about the same channels.
+type nodeout struct {
+	ch  datachan
+	dst NodeID
+
+	// what to do when ch is full, see OverflowPolicy
+	overflow OverflowPolicy
+	timeout  time.Duration
+
+	// quit channel of the destination: once it is closed nobody reads ch
+	// anymore, so blocking sends are abandoned
+	dstQuit chan struct{}
+}
+
+// Sends m on the output channel, applying the overflow policy if the channel is
+// full. Returns whether m was sent, and the message which was dropped to make
+// room for it or instead of it (nil if none was).
+// Blocking sends are abandoned when quit is closed, so that stuck nodes can
+// still be stopped, and when the destination quits.
+func (o nodeout) send(m Message, quit chan struct{}) (bool, *Message) {
+	m.sent = time.Now()
+
+	switch o.overflow {
+	case DROP_NEWEST:
+		select {
+		case o.ch <- m:
+			return true, nil
+		default:
+			return false, &m
+		}
+
+	case DROP_OLDEST:
+		var dropped *Message
+
+		for {
+			select {
+			case o.ch <- m:
+				return true, dropped
+
+			default:
+				// channel full, throw away its oldest message and
+				// try again; note that the receiver may empty the
+				// channel at the same time, so the select may find
+				// nothing to drop
+				select {
+				case old := <-o.ch:
+					dropped = &old
+				default:
+				}
+			}
+		}
+
+	case BLOCK_TIMEOUT:
+		t := time.NewTimer(o.timeout)
+		defer t.Stop()
+
+		select {
+		case o.ch <- m:
+			return true, nil
+		case <-t.C:
+			return false, &m
+		case <-quit:
+			return false, nil
+		case <-o.dstQuit:
+			return false, nil
+		}
+
+	default: // BLOCK
+		select {
+		case o.ch <- m:
+			return true, nil
+		case <-quit:
+			return false, nil
+		case <-o.dstQuit:
+			return false, nil
+		}
+	}
+}
+
+// the code executed by nodes in their goroutines is entirely contained in this function
+func nodeMain(
+	params Node,
+	stopChan chan NodeID,
+	reportChan chan SendReport,
+	dropChan chan DropReport,
+) {
+	id, name := params.ID, params.Name
+	sendText, sendInterval := params.SendText, params.SendInterval
+	relayMode, ttl := params.RelayMode, params.TTL
+	in, ctl, quit := params.in, params.ctl, params.quit
+	status := params.status
+
+	logMsg := func(s string, args ...any) {
+		args = append([]any{name, id}, args...)
+		log.Printf("[%s %v] "+s+"\n", args...)
+	}
+
+	defer logMsg("quit")
+
+	// alert main when this node stops
+	defer func() { stopChan <- id }()
+
+	logMsg("start")
+
+	// output channels for this node
+	var outs []nodeout
+
+	// timer that fires every sendInterval
+	sendTicker := time.NewTicker(2 << 30)
+	if sendInterval > 0 {
+		sendTicker.Reset(sendInterval)
+	} else {
+		sendTicker.Stop()
+	}
+
+	// for round-robin
+	nextOut := 0
+
+	// sequence number of the next message generated by this node
+	nextSeq := 0
+
+	// send a message on output o, and notify main of the send (for
+	// channel usage tracking) or of the drop if the channel was full
+	sendTo := func(o nodeout, m Message) {
+		// let the watchdog know if we get stuck on this send
+		status.sending(o.dst)
+		sent, dropped := o.send(m, quit)
+		status.sent()
+
+		if dropped != nil {
+			logMsg("drop message %v (channel to node %v full)", *dropped, o.dst)
+
+			dropChan <- DropReport{
+				Node:   id,
+				Dst:    o.dst,
+				Msg:    *dropped,
+				Reason: CHAN_FULL,
+			}
+		}
+
+		if sent {
+			reportChan <- SendReport{Src: id, Dst: o.dst, Msg: m}
+		}
+	}
+
+	// input channel when running, nil when paused
+	// we set it to nil when paused so that the select statement below
+	// ignores input messages
+	inOrNil := in
+
+loop: // repeat until main closes the quit channel
+	for {
+		status.loop()
+
+		select {
+		case c := <-ctl:
+			// control message from main received, change the
+			// appropriate parameters
+
+			switch c.action {
+			case SET_NAME:
+				logMsg("change name to %v", c.payload)
+
+				name = c.payload.(string)
+
+			case ADD_DEST:
+				o := c.payload.(nodeout)
+
+				// if there already is a channel to the same
+				// destination, it is replaced (see SetLink)
+				i := slices.IndexFunc(outs, func(p nodeout) bool {
+					return p.dst == o.dst
+				})
+
+				if i >= 0 {
+					logMsg("replace output channel to node %v", o.dst)
+
+					close(outs[i].ch)
+					outs[i] = o
+				} else {
+					logMsg("add output channel to node %v", o.dst)
+
+					outs = append(outs, o)
+				}
+
+			case DEL_DEST:
+				dst := c.payload.(NodeID)
+
+				logMsg("delete output channel to node %v", dst)
+
+				// closing the channel lets its link goroutine
+				// terminate, once it has delivered the
+				// remaining messages
+				outs = slices.DeleteFunc(
+					outs,
+					func(o nodeout) bool {
+						if o.dst == dst {
+							close(o.ch)
+							return true
+						}
+						return false
+					},
+				)
+
+				// the round-robin counter may now be out of range
+				if nextOut >= len(outs) {
+					nextOut = 0
+				}
+
+			case SET_RELAY_MODE:
+				logMsg("change relay mode to %v", c.payload)
+
+				relayMode = c.payload.(RelayMode)
+
+			case SET_SEND_TEXT:
+				logMsg("change send text to node %v", c.payload)
+
+				sendText = c.payload.(string)
+
+			case SET_SEND_INTERVAL:
+				sendInterval = c.payload.(time.Duration)
+
+				logMsg("change send interval to %v ms",
+					float64(sendInterval)/1_000_000)
+
+				if sendInterval > 0 {
+					sendTicker.Reset(sendInterval)
+				} else {
+					sendTicker.Stop()
+				}
+
+			case SET_TTL:
+				logMsg("change TTL to %v", c.payload)
+
+				ttl = c.payload.(int)
+
+			case SET_OVERFLOW:
+				p := c.payload.(nodeout)
+
+				logMsg("change overflow policy of channel to node %v to %v",
+					p.dst, p.overflow)
+
+				for i := range outs {
+					if outs[i].dst == p.dst {
+						outs[i].overflow = p.overflow
+						outs[i].timeout = p.timeout
+					}
+				}
+
+			case TOGGLE_PAUSE:
+				if inOrNil == nil {
+					// currently paused, resume by setting
+					// inOrNil to the input channel in
+					inOrNil = in
+
+					// also resume generating messages
+					if sendInterval > 0 {
+						sendTicker.Reset(sendInterval)
+					}
+
+				} else {
+					// currently running, pause
+					inOrNil = nil     // ignore incoming messages
+					sendTicker.Stop() // stop generating messages
+				}
+			}
+
+		case <-quit:
+			break loop
+
+		case m := <-inOrNil:
+			// incoming message from another node
+			// note that when paused inOrNil is nil, so we don't
+			// handle incoming messages
+
+			logMsg("received message %v after %v", m, time.Since(m.Created))
+			status.received.Add(1)
+
+			// we have to relay the message according to the relayMode,
+			// the relayed copies count one more hop
+			m.Hops++
+
+			if len(outs) == 0 || relayMode == DISCARD {
+				// nothing to do, the message is not relayed
+				break
+			}
+
+			// messages with a TTL can only be relayed TTL times,
+			// after which they are dropped
+			if m.TTL > 0 && m.Hops > m.TTL {
+				logMsg("drop message %v (TTL expired)", m)
+
+				dropChan <- DropReport{Node: id, Msg: m, Reason: TTL_EXPIRED}
+
+				break
+			}
+
+			switch relayMode {
+			case ROUND_ROBIN:
+				// forward to one output only (nextOut)
+
+				o := outs[nextOut]
+
+				logMsg("relay to node %v (round-robin)", o.dst)
+
+				// relay message
+				sendTo(o, m)
+
+				// increment cyclic counter
+				nextOut = (nextOut + 1) % len(outs)
+
+			case MULTICAST:
+				// forward to all outputs
+
+				for _, o := range outs {
+					logMsg("relay to node %v (multicast)", o.dst)
+
+					sendTo(o, m)
+				}
+
+			case DISCARD:
+				// do nothing
+			}
+
+		case <-sendTicker.C:
+			// a message is sent on sendTicker.C every time the
+			// timer fires, i.e. every sendInterval
+
+			// we generate a new message with text sendText and send
+			// it to all the outputs; message generation is always
+			// multicast irrespectively of the relayMode
+
+			m := Message{
+				Text:    sendText,
+				Origin:  id,
+				Seq:     nextSeq,
+				Created: time.Now(),
+				TTL:     ttl,
+			}
+
+			nextSeq++
+
+			for _, o := range outs {
+				logMsg("send message %v to node %v (multicast)", m, o.dst)
+
+				sendTo(o, m)
+			}
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package engine
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Version of the serialization format, written as a graph attribute. Version 1
+// (without the attribute) stored the parameters of the nodes in a comment after
+// each node, version 2 stores them as DOT attributes.
+const FORMAT_VERSION = 2
+
+// Writes the network to the provided io.Writer, in the format of the given
+// codec. The formats are described in the README.org file.
+func (net *Network) Serialize(w io.Writer, c Codec) error {
+	nodes, chans := net.specs(func(NodeID) bool { return true })
+	return c.encode(w, nodes, chans)
+}
+
+// Same as Serialize, but only the nodes in ids are written, together with the
+// channels between them. Used to copy part of the network.
+func (net *Network) SerializeNodes(w io.Writer, c Codec, ids []NodeID) error {
+	nodes, chans := net.specs(func(id NodeID) bool {
+		return slices.Contains(ids, id)
+	})
+
+	return c.encode(w, nodes, chans)
+}
+
+// Sets whether Serialize keeps the order of the nodes in the files they were
+// loaded from. By default the nodes are written sorted by ID, so that saving
+// the same network twice gives the same file; with the order of the file
+// instead, a file which is loaded and saved again changes only where the
+// network did. Nodes which were not loaded from a file are written after the
+// others, by ID.
+func (net *Network) SetPreserveOrder(preserve bool) {
+	net.preserveOrder = preserve
+}
+
+// Returns the IDs of the nodes for which keep returns true, in the order in
+// which they are written: by ID, or as in the loaded files if preserveOrder is
+// set.
+func (net *Network) sortedIDs(keep func(NodeID) bool) []NodeID {
+	var ids []NodeID
+	for id := range net.nodes {
+		if keep(id) {
+			ids = append(ids, id)
+		}
+	}
+
+	slices.Sort(ids)
+
+	if !net.preserveOrder {
+		return ids
+	}
+
+	// position of each node in the loaded files; a node loaded twice (by
+	// Merge, after its ID was freed) takes the last one
+	pos := make(map[NodeID]int, len(net.order))
+	for i, id := range net.order {
+		pos[id] = i
+	}
+
+	// the others go last, still by ID since the sort is stable
+	slices.SortStableFunc(ids, func(a, b NodeID) int {
+		pa, okA := pos[a]
+		pb, okB := pos[b]
+
+		switch {
+		case okA && okB:
+			return pa - pb
+		case okA:
+			return -1
+		case okB:
+			return 1
+		default:
+			return 0
+		}
+	})
+
+	return ids
+}
+
+// Returns the specifications of the nodes for which keep returns true, and of
+// the channels between them. The order is stable, see sortedIDs: the channels
+// follow their source, sorted by destination (or in the order in which they
+// were created, if preserveOrder is set).
+func (net *Network) specs(keep func(NodeID) bool) ([]nodespec, []chanspec) {
+	var nodes []nodespec
+	var chans []chanspec
+
+	for _, id := range net.sortedIDs(keep) {
+		n := net.nodes[id]
+
+		nodes = append(nodes, nodespec{
+			id:           id,
+			name:         n.Name,
+			sendText:     n.SendText,
+			sendInterval: n.SendInterval,
+			relayMode:    n.RelayMode,
+			paused:       n.Paused,
+			x:            n.X,
+			y:            n.Y,
+			ttl:          n.TTL,
+		})
+
+		outs := slices.Clone(n.Outs)
+		if !net.preserveOrder {
+			slices.SortFunc(outs, func(a, b ChanInfo) int {
+				return int(a.Dst - b.Dst)
+			})
+		}
+
+		for _, o := range outs {
+			if keep(o.Dst) {
+				chans = append(chans, chanspec{id, o})
+			}
+		}
+	}
+
+	return nodes, chans
+}
+
+type dotCodec struct{}
+
+// writes each node followed by its output channels
+func (dotCodec) encode(w io.Writer, nodes []nodespec, chans []chanspec) error {
+	outs := make(map[NodeID][]ChanInfo)
+	for _, c := range chans {
+		outs[c.src] = append(outs[c.src], c.info)
+	}
+
+	fmt.Fprintln(w, "digraph network {")
+	fmt.Fprintf(w, "graph [format_version=%d]\n\n", FORMAT_VERSION)
+
+	for _, n := range nodes {
+		// format:
+		// <id> [label=<name>, send_text=<sendText>, interval_ms=<sendInterval>, ...]
+		fmt.Fprintf(w, "%d %s\n", n.id, n.attrs())
+
+		// also write all outgoing channels, with their parameters as
+		// attributes
+		for _, o := range outs[n.id] {
+			fmt.Fprintf(w, "%d -> %d%s\n", n.id, o.Dst, o.attrs())
+		}
+
+		fmt.Fprintln(w, "")
+	}
+
+	_, err := fmt.Fprintln(w, "}")
+
+	return err
+}
+
+func (dotCodec) decode(r io.Reader) ([]nodespec, []chanspec, error) {
+	return parseDOT(r)
+}
+
+// Returns the DOT attribute list of a node, e.g.
+//
+//	[label="node", send_text="from 0", interval_ms=1000, relay=round_robin, pos="100,-200!"]
+//
+// The paused state and the TTL are only written if they are set. The strings
+// are quoted with Go syntax, which the parser reads back, so that they can
+// contain quotes, newlines and any other character.
+//
+// The position is written in the coordinates of Graphviz, where y grows
+// upwards, so that the network can be drawn as it is shown by the program with
+// `neato -n`; the "!" tells Graphviz not to move the node.
+func (n nodespec) attrs() string {
+	attrs := []string{
+		"label=" + strconv.Quote(n.name),
+		"send_text=" + strconv.Quote(n.sendText),
+		fmt.Sprintf("interval_ms=%d", n.sendInterval.Milliseconds()),
+		fmt.Sprintf("relay=%v", n.relayMode),
+	}
+
+	if n.paused {
+		attrs = append(attrs, "paused=true")
+	}
+
+	attrs = append(attrs, fmt.Sprintf("pos=\"%d,%d!\"", n.x, -n.y))
+
+	if n.ttl != 0 {
+		attrs = append(attrs, fmt.Sprintf("ttl=%d", n.ttl))
+	}
+
+	return "[" + strings.Join(attrs, ", ") + "]"
+}
+
+// Returns the DOT attribute list of a channel, e.g.
+//
+//	[buf_size=16, delay_ms=100]
+//
+// Only parameters which differ from the defaults are written; if none does, the
+// result is empty.
+func (c ChanInfo) attrs() string {
+	params := c.params()
+	if len(params) == 0 {
+		return ""
+	}
+
+	return " [" + strings.Join(params, ", ") + "]"
+}
+
+// returns the parameters of the channel which differ from the defaults, as
+// name=value pairs
+func (c ChanInfo) params() []string {
+	var params []string
+
+	if c.Link.BufSize != DEFAULT_LINK.BufSize {
+		params = append(params, fmt.Sprintf("buf_size=%d", c.Link.BufSize))
+	}
+
+	if c.Link.Delay != 0 {
+		params = append(params, fmt.Sprintf("delay_ms=%d", c.Link.Delay.Milliseconds()))
+	}
+
+	if c.Link.Jitter != 0 {
+		params = append(params, fmt.Sprintf("jitter_ms=%d", c.Link.Jitter.Milliseconds()))
+	}
+
+	if c.Link.Loss != 0 {
+		// without exponent, which DOT numerals do not have
+		params = append(params, "loss="+strconv.FormatFloat(c.Link.Loss, 'f', -1, 64))
+	}
+
+	if c.Overflow != BLOCK {
+		params = append(params, fmt.Sprintf("overflow=%v", c.Overflow))
+	}
+
+	if c.Timeout != 0 {
+		params = append(params, fmt.Sprintf("timeout_ms=%d", c.Timeout.Milliseconds()))
+	}
+
+	return params
+}
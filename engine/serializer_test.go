@@ -0,0 +1,202 @@
+package engine
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// names and texts which are hard to quote
+var trickyStrings = []string{
+	"",
+	"plain",
+	"with spaces",
+	`"quoted"`,
+	`back\slash`,
+	`C:\Net\Node`,
+	`\N`,
+	`\N and \\N`,
+	`trailing\`,
+	"new\nline",
+	"carriage\r\nreturn",
+	"tab\there",
+	"nul\x00byte",
+	"invalid \xff\xfe utf-8",
+	"àèìòù ñ ß",
+	"日本語のノード",
+	"emoji 🛰️📡",
+	"\u00a0non-breaking\u2028separator",
+	"-> [label=x] // not a comment",
+	"/* comment */ # hash",
+	"<html>&amp;</html>",
+	"{\"json\": [1, 2]}",
+}
+
+// returns a random string of at most n runes, mixing ASCII, control
+// characters, arbitrary Unicode and bytes which are not valid UTF-8
+func randomString(r *rand.Rand, n int) string {
+	var b strings.Builder
+
+	for range r.Intn(n + 1) {
+		switch r.Intn(5) {
+		case 0:
+			b.WriteByte(byte(r.Intn(0x80)))
+		case 1:
+			b.WriteString([]string{`"`, `\`, "\n", "\x00", `\N`}[r.Intn(5)])
+		case 2:
+			b.WriteByte(byte(0x80 + r.Intn(0x80)))
+		default:
+			c := rune(r.Intn(utf8.MaxRune + 1))
+			if !utf8.ValidRune(c) {
+				c = utf8.RuneError
+			}
+			b.WriteRune(c)
+		}
+	}
+
+	return b.String()
+}
+
+// spawns a node for each pair of name and send text, with a channel from each
+// node to the next one, and returns the network
+func tricky(names, texts []string) *Network {
+	net := New()
+
+	var prev NodeID
+	for i := range names {
+		id := net.Spawn(i*10, -i*20)
+
+		net.SetName(id, names[i])
+		net.SetSendText(id, texts[i])
+		net.SetSendInterval(id, 0)
+		net.SetTTL(id, i%3)
+
+		if i > 0 {
+			net.Connect(prev, id)
+		}
+		prev = id
+	}
+
+	return net
+}
+
+// Checks that serializing the network, loading it back and serializing it
+// again gives the same bytes, and that the names and texts are kept (when they
+// can be represented in the format).
+func checkRoundTrip(t *testing.T, net *Network, c Codec, exactStrings bool) {
+	t.Helper()
+
+	var first bytes.Buffer
+	if err := net.Serialize(&first, c); err != nil {
+		t.Fatalf("serialize: %v", err)
+	}
+
+	loaded := New()
+	defer loaded.StopAllAndWait()
+
+	if err := loaded.Deserialize(bytes.NewReader(first.Bytes()), c); err != nil {
+		t.Fatalf("deserialize: %v\n%s", err, first.String())
+	}
+
+	var second bytes.Buffer
+	if err := loaded.Serialize(&second, c); err != nil {
+		t.Fatalf("serialize again: %v", err)
+	}
+
+	if !bytes.Equal(first.Bytes(), second.Bytes()) {
+		t.Fatalf("output changed after a round trip:\n%s\n---\n%s",
+			first.String(), second.String())
+	}
+
+	if !exactStrings {
+		return
+	}
+
+	for id, n := range net.Nodes() {
+		m, ok := loaded.Node(id)
+		if !ok {
+			t.Fatalf("node %v missing after a round trip", id)
+		}
+
+		if m.Name != n.Name {
+			t.Errorf("node %v: name %q loaded as %q", id, n.Name, m.Name)
+		}
+
+		if m.SendText != n.SendText {
+			t.Errorf("node %v: send text %q loaded as %q", id, n.SendText, m.SendText)
+		}
+	}
+}
+
+// JSON replaces invalid UTF-8 with U+FFFD, so only the DOT codec keeps the
+// strings exactly; the output must still be stable
+var roundTripCodecs = []struct {
+	name         string
+	codec        Codec
+	exactStrings bool
+}{
+	{"dot", DOT, true},
+	{"json", JSON, false},
+}
+
+func TestRoundTripTrickyStrings(t *testing.T) {
+	texts := make([]string, len(trickyStrings))
+	for i := range texts {
+		texts[i] = trickyStrings[len(trickyStrings)-1-i]
+	}
+
+	net := tricky(trickyStrings, texts)
+	defer net.StopAllAndWait()
+
+	for _, rc := range roundTripCodecs {
+		t.Run(rc.name, func(t *testing.T) {
+			checkRoundTrip(t, net, rc.codec, rc.exactStrings)
+		})
+	}
+}
+
+func TestRoundTripRandomStrings(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	for i := range 20 {
+		names := make([]string, 5)
+		texts := make([]string, 5)
+		for k := range names {
+			names[k] = randomString(r, 30)
+			texts[k] = randomString(r, 30)
+		}
+
+		net := tricky(names, texts)
+
+		for _, rc := range roundTripCodecs {
+			ok := t.Run(rc.name, func(t *testing.T) {
+				checkRoundTrip(t, net, rc.codec, rc.exactStrings)
+			})
+
+			if !ok {
+				t.Logf("names %q, texts %q (iteration %d)", names, texts, i)
+			}
+		}
+
+		net.StopAllAndWait()
+	}
+}
+
+// link parameters, including tiny and huge values, survive a round trip
+func TestRoundTripLinkParams(t *testing.T) {
+	net := tricky([]string{"a", "b", "c"}, []string{"x", "y", "z"})
+	defer net.StopAllAndWait()
+
+	net.SetLink(0, 1, LinkParams{BufSize: 3, Delay: 5 * time.Millisecond, Loss: 0.00001})
+	net.SetLink(1, 2, LinkParams{BufSize: 1 << 20, Jitter: time.Hour, Loss: 1})
+	net.SetOverflow(1, 2, BLOCK_TIMEOUT, 250*time.Millisecond)
+
+	for _, rc := range roundTripCodecs {
+		t.Run(rc.name, func(t *testing.T) {
+			checkRoundTrip(t, net, rc.codec, rc.exactStrings)
+		})
+	}
+}
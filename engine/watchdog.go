@@ -0,0 +1,216 @@
+package engine
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+// Since sends between nodes can block, and control messages are handled by the
+// same loop that sends messages, a cycle of full channels can wedge the nodes
+// in it forever. The watchdog detects nodes which have been blocked for too
+// long, and looks for cycles of nodes blocked sending to each other.
+
+// default value for the stall timeout of new networks
+const DEFAULT_STALL_TIMEOUT = 3 * time.Second
+
+// State shared by each node with the main goroutine, mostly for the watchdog.
+// The node writes it and main reads it, so all fields are atomic.
+type nodeStatus struct {
+	// number of messages received by the node
+	received atomic.Int64
+
+	// last time the node went through its main loop (in unix nanoseconds)
+	lastLoop atomic.Int64
+
+	// destination of the send the node is currently blocked on, or -1,
+	// and since when (in unix nanoseconds)
+	blockedOn    atomic.Int64
+	blockedSince atomic.Int64
+}
+
+func newNodeStatus() *nodeStatus {
+	s := &nodeStatus{}
+	s.lastLoop.Store(time.Now().UnixNano())
+	s.blockedOn.Store(-1)
+	return s
+}
+
+// called by the node on every iteration of its main loop
+func (s *nodeStatus) loop() {
+	s.lastLoop.Store(time.Now().UnixNano())
+}
+
+// called by the node before and after sending on an output channel
+func (s *nodeStatus) sending(dst NodeID) {
+	s.blockedSince.Store(time.Now().UnixNano())
+	s.blockedOn.Store(int64(dst))
+}
+
+func (s *nodeStatus) sent() {
+	s.blockedOn.Store(-1)
+}
+
+// Returns true if the node has been stuck for longer than timeout, i.e. it has
+// been blocked on a send, or it has pending control messages but has not gone
+// through its main loop. The first return value is the destination of the
+// blocked send, or -1 if the node is not sending.
+func (s *nodeStatus) stalled(now time.Time, timeout time.Duration, pendingCtl int) (NodeID, bool) {
+	limit := now.Add(-timeout).UnixNano()
+
+	if dst := s.blockedOn.Load(); dst >= 0 && s.blockedSince.Load() < limit {
+		return NodeID(dst), true
+	}
+
+	if pendingCtl > 0 && s.lastLoop.Load() < limit {
+		return -1, true
+	}
+
+	return -1, false
+}
+
+// Sets how long a node can be blocked before the watchdog reports it as
+// stalled. A timeout of 0 disables the watchdog.
+func (net *Network) SetStallTimeout(d time.Duration) {
+	net.stallTimeout = d
+
+	if d <= 0 {
+		net.stalled = nil
+		net.deadlocks = nil
+	}
+}
+
+// Returns the nodes currently reported as stalled by the watchdog, each with the
+// node it is blocked sending to (-1 if it is stuck for other reasons).
+// The map is owned by the network and must not be modified.
+func (net *Network) Stalled() map[NodeID]NodeID {
+	return net.stalled
+}
+
+// Returns the cycles of nodes blocked sending to each other found by the
+// watchdog. In each cycle, every node is blocked sending to the next one, and
+// the last one to the first.
+func (net *Network) Deadlocks() [][]NodeID {
+	return net.deadlocks
+}
+
+// called by Poll, updates the stalled nodes and deadlocks and logs the changes
+func (net *Network) watchdog() {
+	if net.stallTimeout <= 0 {
+		return
+	}
+
+	now := time.Now()
+	stalled := make(map[NodeID]NodeID)
+
+	for id, n := range net.nodes {
+		if dst, ok := n.status.stalled(now, net.stallTimeout, len(n.ctl)); ok {
+			stalled[id] = dst
+		}
+	}
+
+	for id, dst := range stalled {
+		if _, ok := net.stalled[id]; ok {
+			continue
+		}
+
+		if dst >= 0 {
+			log.Printf("[watchdog] node %v stalled, blocked sending to node %v", id, dst)
+		} else {
+			log.Printf("[watchdog] node %v stalled, not handling control messages", id)
+		}
+	}
+
+	for id := range net.stalled {
+		if _, ok := stalled[id]; !ok {
+			log.Printf("[watchdog] node %v recovered", id)
+		}
+	}
+
+	deadlocks := findCycles(stalled)
+
+	for _, c := range deadlocks {
+		if !slices.ContainsFunc(net.deadlocks, func(d []NodeID) bool {
+			return slices.Equal(c, d)
+		}) {
+			log.Printf("[watchdog] deadlock: %s", formatCycle(c))
+		}
+	}
+
+	net.stalled = stalled
+	net.deadlocks = deadlocks
+}
+
+// Finds the cycles in the graph where each stalled node points to the node it is
+// blocked on. Since every node has at most one outgoing edge, cycles are
+// disjoint and can be found by following the edges from each node.
+// Each cycle starts from its smallest ID, so that it is always the same slice.
+func findCycles(blockedOn map[NodeID]NodeID) [][]NodeID {
+	const (
+		unvisited = iota
+		onPath
+		done
+	)
+
+	state := make(map[NodeID]int)
+	var cycles [][]NodeID
+
+	// sort the starting points, so that the result does not depend on the
+	// map iteration order
+	starts := make([]NodeID, 0, len(blockedOn))
+	for id := range blockedOn {
+		starts = append(starts, id)
+	}
+	slices.Sort(starts)
+
+	for _, start := range starts {
+		var path []NodeID
+
+		id := start
+		for {
+			if state[id] == onPath {
+				// back to a node of the current path: the
+				// path from there on is a cycle
+				c := slices.Clone(path[slices.Index(path, id):])
+
+				m := slices.Index(c, slices.Min(c))
+				c = append(c[m:], c[:m]...)
+
+				cycles = append(cycles, c)
+				break
+			}
+
+			if state[id] == done {
+				break
+			}
+
+			state[id] = onPath
+			path = append(path, id)
+
+			next, ok := blockedOn[id]
+			if !ok || next < 0 {
+				break
+			}
+
+			id = next
+		}
+
+		for _, p := range path {
+			state[p] = done
+		}
+	}
+
+	return cycles
+}
+
+// formats a cycle as "1 -> 2 -> 3 -> 1"
+func formatCycle(c []NodeID) string {
+	s := ""
+	for _, id := range c {
+		s += fmt.Sprintf("%v -> ", id)
+	}
+
+	return s + fmt.Sprint(c[0])
+}
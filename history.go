@@ -0,0 +1,235 @@
+// This file implements undo and redo of the changes made to the network.
+//
+// Every edit is recorded as the configuration of the network before and after
+// it. Undoing an edit does not rebuild the network: the differences between
+// the current configuration and the old one are applied to the running nodes,
+// so that nodes and channels not affected by the edit keep running undisturbed.
+// Deleted nodes are spawned again with the same ID.
+
+package main
+
+import (
+	"log"
+	"maps"
+	"time"
+
+	"network-manager/engine"
+)
+
+// maximum number of edits that can be undone
+const MAX_HISTORY = 100
+
+// configuration of a channel, without its statistics
+type chanConfig struct {
+	link     engine.LinkParams
+	overflow engine.OverflowPolicy
+	timeout  time.Duration
+}
+
+// configuration of a node, with its output channels
+type nodeConfig struct {
+	name         string
+	sendText     string
+	sendInterval time.Duration
+	relayMode    engine.RelayMode
+	ttl          int
+	paused       bool
+	x, y         int
+
+	outs map[engine.NodeID]chanConfig
+}
+
+// configuration of the whole network, indexed by node ID
+type netConfig map[engine.NodeID]nodeConfig
+
+// an edit, as the configuration of the network before and after it
+type edit struct {
+	before, after netConfig
+}
+
+type history struct {
+	undo []edit
+	redo []edit
+}
+
+// channels created by Connect have these parameters
+var DEFAULT_CHAN = chanConfig{link: engine.DEFAULT_LINK}
+
+func (a nodeConfig) equal(b nodeConfig) bool {
+	return a.name == b.name &&
+		a.sendText == b.sendText &&
+		a.sendInterval == b.sendInterval &&
+		a.relayMode == b.relayMode &&
+		a.ttl == b.ttl &&
+		a.paused == b.paused &&
+		a.x == b.x && a.y == b.y &&
+		maps.Equal(a.outs, b.outs)
+}
+
+// returns the current configuration of the network; stopped nodes are
+// considered already deleted
+func (g *Game) config() netConfig {
+	c := make(netConfig)
+
+	for id, n := range g.net.Nodes() {
+		if n.Stopped() {
+			continue
+		}
+
+		nc := nodeConfig{
+			name:         n.Name,
+			sendText:     n.SendText,
+			sendInterval: n.SendInterval,
+			relayMode:    n.RelayMode,
+			ttl:          n.TTL,
+			paused:       n.Paused,
+			x:            n.X,
+			y:            n.Y,
+			outs:         make(map[engine.NodeID]chanConfig),
+		}
+
+		for _, o := range n.Outs {
+			nc.outs[o.Dst] = chanConfig{o.Link, o.Overflow, o.Timeout}
+		}
+
+		c[id] = nc
+	}
+
+	return c
+}
+
+// Changes the running network so that its configuration matches c, sending
+// to the nodes only the changes that are needed.
+func (g *Game) applyConfig(c netConfig) {
+	cur := g.config()
+
+	// first stop the nodes which are not in c, and spawn those which are
+	// missing, so that all the channels can be created afterwards
+	for id := range cur {
+		if _, ok := c[id]; !ok {
+			g.net.Stop(id)
+		}
+	}
+
+	for id, nc := range c {
+		if _, ok := cur[id]; !ok {
+			g.net.SpawnWithID(id, nc.x, nc.y)
+		}
+	}
+
+	cur = g.config()
+
+	for id, nc := range c {
+		old := cur[id]
+
+		if old.name != nc.name {
+			g.net.SetName(id, nc.name)
+		}
+
+		if old.sendText != nc.sendText {
+			g.net.SetSendText(id, nc.sendText)
+		}
+
+		if old.sendInterval != nc.sendInterval {
+			g.net.SetSendInterval(id, nc.sendInterval)
+		}
+
+		if old.relayMode != nc.relayMode {
+			g.net.SetRelayMode(id, nc.relayMode)
+		}
+
+		if old.ttl != nc.ttl {
+			g.net.SetTTL(id, nc.ttl)
+		}
+
+		if old.paused != nc.paused {
+			g.net.TogglePause(id)
+		}
+
+		if old.x != nc.x || old.y != nc.y {
+			g.net.Move(id, nc.x, nc.y)
+		}
+
+		for dst := range old.outs {
+			if _, ok := nc.outs[dst]; !ok {
+				g.net.Disconnect(id, dst)
+			}
+		}
+
+		for dst, cc := range nc.outs {
+			oldc, ok := old.outs[dst]
+			if !ok {
+				g.net.Connect(id, dst)
+				oldc = DEFAULT_CHAN
+			}
+
+			if oldc.link != cc.link {
+				g.net.SetLink(id, dst, cc.link)
+			}
+
+			if oldc.overflow != cc.overflow || oldc.timeout != cc.timeout {
+				g.net.SetOverflow(id, dst, cc.overflow, cc.timeout)
+			}
+		}
+	}
+}
+
+// Runs f, which changes the network, and records the change in the history.
+// Edits which do not change the configuration are not recorded.
+func (g *Game) edit(f func()) {
+	before := g.config()
+	f()
+	g.record(before)
+}
+
+// Records the change from before to the current configuration, for edits
+// which span several updates, like dragging nodes around.
+func (g *Game) record(before netConfig) {
+	after := g.config()
+
+	if maps.EqualFunc(before, after, nodeConfig.equal) {
+		return
+	}
+
+	h := &g.history
+
+	h.undo = append(h.undo, edit{before, after})
+	if len(h.undo) > MAX_HISTORY {
+		h.undo = h.undo[1:]
+	}
+
+	// a new edit makes the undone ones unreachable
+	h.redo = nil
+}
+
+func (g *Game) undo() {
+	h := &g.history
+
+	if len(h.undo) == 0 {
+		return
+	}
+
+	e := h.undo[len(h.undo)-1]
+	h.undo = h.undo[:len(h.undo)-1]
+
+	log.Printf("[manager] undo")
+	g.applyConfig(e.before)
+
+	h.redo = append(h.redo, e)
+}
+
+func (g *Game) redo() {
+	h := &g.history
+
+	if len(h.redo) == 0 {
+		return
+	}
+
+	e := h.redo[len(h.redo)-1]
+	h.redo = h.redo[:len(h.redo)-1]
+
+	log.Printf("[manager] redo")
+	g.applyConfig(e.after)
+
+	h.undo = append(h.undo, e)
+}
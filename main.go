@@ -1,15 +1,22 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"image"
 	"log"
 	"math"
+	"time"
 
 	"image/color"
 
+	"network-manager/engine"
+	"network-manager/render"
+
 	"github.com/ebitenui/ebitenui"
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
 	"github.com/hajimehoshi/ebiten/v2/vector"
 )
 
@@ -18,21 +25,52 @@ type Game struct {
 	ui          *ebitenui.UI
 	toolbarRect image.Rectangle // to detect clicks on the toolbar
 
-	xPan, yPan int // world origin in screen space
+	xPan, yPan int     // world origin in screen space
+	zoom       float64 // screen pixels per world unit
+
+	screenW, screenH int // size of the window, for fitting the view
 
 	wmx, wmy int // world mouse coordinates
 	smx, smy int // screen mouse coordinates
 
-	net        network         // info about the nodes in the network
-	stopChan   chan nodeid     // used by nodes to signal termination
-	reportChan chan sendreport // used by nodes to report every send (for coloring edges)
+	net *engine.Network // the running nodes
+
+	// usage tracker of each channel, used for coloring edges
+	usage map[edge]float64
+
+	// messages travelling on the channels, drawn as moving dots
+	flow flow
+
+	connecting  bool          // true if currently drawing a new edge
+	connectFrom engine.NodeID // source of new edge
+
+	dragging  bool          // true if the left button was pressed on a node
+	dragged   bool          // true if the node has been moved since then
+	dragNode  engine.NodeID // node being dragged
+	dragStart netConfig     // configuration before the drag, for undo
 
-	connecting  bool   // true if currently drawing a new edge
-	connectFrom nodeid // source of new edge
+	selectedNode engine.NodeID // current node for popup window
+	selectedEdge edge          // current edge for popup window
 
-	selectedNode nodeid // current node for popup window
+	// nodes selected with the rubber band or with shift-click, for the
+	// operations on several nodes at once
+	selection map[engine.NodeID]bool
+
+	selecting  bool // true while drawing the rubber band
+	selX, selY int  // world coordinates where the rubber band started
+
+	// true if the next click on a node connects all the selected nodes
+	// to it
+	connectingSelection bool
+
+	labels labelMode // what is written next to each node
+
+	history history // edits that can be undone and redone
 }
 
+// a channel is identified by its endpoints
+type edge struct{ src, dst engine.NodeID }
+
 type tool int
 
 const (
@@ -42,30 +80,95 @@ const (
 
 var currentTool tool
 
+// Nodes can have a label next to them on the canvas, with just their name or
+// also their ID, relay mode and the messages they received and sent. Labels
+// can be hidden on dense graphs, where they would overlap.
+type labelMode int
+
+const (
+	LABELS_NAME labelMode = iota
+	LABELS_DETAILS
+	LABELS_NONE
+)
+
+// switches to the next label mode, wrapping around
+func (m labelMode) next() labelMode {
+	return (m + 1) % (LABELS_NONE + 1)
+}
+
+// smaller than the UI font; it is a text/v2 face, since the labels are drawn
+// directly on the screen and not by ebitenui
+var labelFace *text.GoXFace
+
 // Channels have an usage tracker that is used to compute the color of the
 // channel's edge in the UI. Channels used more recently have a darker shade of
 // gray. The usage is a float in range [0, 1], and it is reduced by
 // CHAN_USAGE_DECAY on every update.
 const CHAN_USAGE_DECAY = 0.02
 
+// color of the border of selected nodes and of the rubber band
+var SELECTION_COLOR = color.RGBA{0x1f, 0x77, 0xb4, 0xff}
+
+// color of stalled nodes and of the channels in a deadlock
+var DEADLOCK_COLOR = color.RGBA{0xDD, 0x22, 0x22, 0xFF}
+
+// limits of the zoom factor, and how much it changes for each step of the
+// mouse wheel or press of the +/- keys
+const MIN_ZOOM = 0.1
+const MAX_ZOOM = 10.0
+const ZOOM_STEP = 1.1
+
+// space left around the nodes by "fit to view", in pixels
+const FIT_MARGIN = 30
+
 func main() {
 	var err error
 
-	// fill some global variables with images for nodes, buttons etc.
+	stallTimeout := flag.Duration("stall-timeout", engine.DEFAULT_STALL_TIMEOUT,
+		"report nodes blocked for longer than this (0 to disable)")
+	travelTime := flag.Duration("travel-time", DEFAULT_TRAVEL_TIME,
+		"time taken by a message to cross a channel on screen (0 to disable)")
+	preserveOrder := flag.Bool("preserve-order", false,
+		"save the nodes in the order of the loaded file, instead of by ID")
+	flag.Parse()
+
+	// fill some global variables with images for buttons etc.
 	makeImages()
 
-	face, err = loadFont(20)
+	face, err = render.LoadFont(20)
+	if err != nil {
+		log.Fatal(err)
+		return
+	}
+
+	f, err := render.LoadFont(render.LABEL_FONT_SIZE)
 	if err != nil {
 		log.Fatal(err)
 		return
 	}
 
+	labelFace = text.NewGoXFace(f)
+
 	game := Game{
-		net:        make(network),
-		stopChan:   make(chan nodeid, 32),
-		reportChan: make(chan sendreport, 1024),
+		net:       engine.New(),
+		usage:     make(map[edge]float64),
+		zoom:      1,
+		flow:      flow{travelTime: *travelTime, stride: 1},
+		selection: make(map[engine.NodeID]bool),
 	}
 
+	game.net.SetStallTimeout(*stallTimeout)
+	game.net.SetPreserveOrder(*preserveOrder)
+
+	// every time src sends a message to dst, set the usage tracker of
+	// the channel between them to 1
+	game.net.Subscribe(func(r engine.SendReport) {
+		game.usage[edge{r.Src, r.Dst}] = 1
+	})
+
+	// and spawn a dot that travels from src to dst
+	game.net.Subscribe(game.flow.report)
+
 	// toolbarRect is the area under the buttons at the top of the screen
 	ui, toolbarRect := makeUI(&game)
 
@@ -85,19 +188,20 @@ func main() {
 
 // required for window resizing
 func (g *Game) Layout(outsideWidth int, outsideHeight int) (int, int) {
+	g.screenW, g.screenH = outsideWidth, outsideHeight
 	return outsideWidth, outsideHeight
 }
 
 // returns the node at the current mouse position
 // the second return value is false if no node was found
-func (g *Game) nodeAtMouse() (nodeid, bool) {
+func (g *Game) nodeAtMouse() (engine.NodeID, bool) {
 	// look for a node which is at (manhattan) distance r from the mouse
-	// should be nodeSize/2 but we double it to help with misclicks
-	r := nodeSize
+	// should be NODE_SIZE/2 but we double it to help with misclicks
+	r := render.NODE_SIZE
 
 	// scan all nodes and return the first that matches
-	for k, n := range g.net {
-		if abs(g.wmx-n.x) <= r && abs(g.wmy-n.y) <= r {
+	for k, n := range g.net.Nodes() {
+		if abs(g.wmx-n.X) <= r && abs(g.wmy-n.Y) <= r {
 			return k, true
 		}
 	}
@@ -105,70 +209,138 @@ func (g *Game) nodeAtMouse() (nodeid, bool) {
 	return -1, false
 }
 
+// returns the channel whose line passes under the mouse pointer
+// the second return value is false if no channel was found
+func (g *Game) edgeAtMouse() (edge, bool) {
+	// maximum distance of the pointer from the line, 4 pixels on screen
+	r := 4 / g.zoom
+
+	px, py := float64(g.wmx), float64(g.wmy)
+
+	for k, n := range g.net.Nodes() {
+		for _, o := range n.Outs {
+			dst, _ := g.net.Node(o.Dst)
+
+			x0, y0 := float64(n.X), float64(n.Y)
+			x1, y1 := float64(dst.X), float64(dst.Y)
+
+			// project the pointer on the segment, clamping to its
+			// endpoints, and measure the distance from there
+			dx, dy := x1-x0, y1-y0
+			l := dx*dx + dy*dy
+			if l == 0 {
+				continue
+			}
+
+			t := ((px-x0)*dx + (py-y0)*dy) / l
+			t = math.Max(0, math.Min(1, t))
+
+			if math.Hypot(x0+t*dx-px, y0+t*dy-py) <= r {
+				return edge{k, o.Dst}, true
+			}
+		}
+	}
+
+	return edge{}, false
+}
+
 // returns position of node in screen space,
-// i.e. offset by the values of xPan and yPan
-func (g *Game) nodeScreenPos(id nodeid) (x, y int) {
-	x = g.net[id].x + g.xPan
-	y = g.net[id].y + g.yPan
+// i.e. scaled by zoom and offset by the values of xPan and yPan
+func (g *Game) nodeScreenPos(id engine.NodeID) (x, y int) {
+	n, _ := g.net.Node(id)
+	return g.toScreen(n.X, n.Y)
+}
+
+// converts world coordinates to screen coordinates
+func (g *Game) toScreen(wx, wy int) (x, y int) {
+	x = int(math.Round(float64(wx)*g.zoom)) + g.xPan
+	y = int(math.Round(float64(wy)*g.zoom)) + g.yPan
 	return
 }
 
+// converts screen coordinates to world coordinates
+func (g *Game) toWorld(sx, sy int) (x, y int) {
+	x = int(math.Round(float64(sx-g.xPan) / g.zoom))
+	y = int(math.Round(float64(sy-g.yPan) / g.zoom))
+	return
+}
+
+// multiplies the zoom factor by f, keeping the world point at screen
+// coordinates (sx, sy) still
+func (g *Game) zoomAt(f float64, sx, sy int) {
+	z := min(MAX_ZOOM, max(MIN_ZOOM, g.zoom*f))
+
+	// world coordinates of the fixed point, before zooming
+	wx := float64(sx-g.xPan) / g.zoom
+	wy := float64(sy-g.yPan) / g.zoom
+
+	g.zoom = z
+	g.xPan = sx - int(math.Round(wx*z))
+	g.yPan = sy - int(math.Round(wy*z))
+}
+
+// sets zoom and pan so that all the nodes are visible
+func (g *Game) fitToView() {
+	nodes := g.net.Nodes()
+	if len(nodes) == 0 {
+		return
+	}
+
+	// bounding box of the nodes in world space
+	x0, y0 := math.MaxInt, math.MaxInt
+	x1, y1 := math.MinInt, math.MinInt
+
+	for _, n := range nodes {
+		x0, y0 = min(x0, n.X), min(y0, n.Y)
+		x1, y1 = max(x1, n.X), max(y1, n.Y)
+	}
+
+	// area of the screen available for the nodes: leave a margin around,
+	// and some space for the toolbar at the top
+	top := g.toolbarRect.Max.Y + FIT_MARGIN
+	w := float64(g.screenW - 2*FIT_MARGIN)
+	h := float64(g.screenH - top - FIT_MARGIN)
+
+	z := MAX_ZOOM
+	if x1 > x0 {
+		z = min(z, w/float64(x1-x0))
+	}
+	if y1 > y0 {
+		z = min(z, h/float64(y1-y0))
+	}
+
+	g.zoom = max(MIN_ZOOM, z)
+
+	// center the bounding box in the available area
+	g.xPan = FIT_MARGIN + int(w/2-float64(x0+x1)/2*g.zoom)
+	g.yPan = top + int(h/2-float64(y0+y1)/2*g.zoom)
+}
+
 // Update is called by ebiten 60 times per second,
 // and implements the logic of the main goroutine.
 func (g *Game) Update() error {
 	// For every channel, we keep a usage tracker which is used to compute
 	// the color of the channel's edge in the visualization. Every update,
 	// we apply decay to all the trackers, reducing them by CHAN_USAGE_DECAY.
-	for i := range g.net {
-		n := g.net[i]
-
-		for j := range n.outs {
-			u := n.outs[j].usage
-			n.outs[j].usage = max(0, u-CHAN_USAGE_DECAY)
-		}
-
-		// Go forbids direct modification of values in maps,
-		// so we often need to copy the node struct into
-		// a variable (n), modify the copy, and then write it
-		// back into the map g.net
-		g.net[i] = n
-	}
-
-loop1: // handle messages on reportChan
-	for {
-		select {
-		case r := <-g.reportChan:
-			// r is a struct of type sendreport
-			// it contains a pair of node IDs, src and dst
-			// it means that src has sent a message to dst
-
-			n := g.net[r.src]
-
-			// find the channel from src to dst and set its usage
-			// tracker to 1
-			for i := range n.outs {
-				if n.outs[i].dst == r.dst {
-					n.outs[i].usage = 1
-				}
-			}
-
-			g.net[r.src] = n
-
-		default:
-			break loop1
+	for e, u := range g.usage {
+		if u <= CHAN_USAGE_DECAY {
+			delete(g.usage, e)
+		} else {
+			g.usage[e] = u - CHAN_USAGE_DECAY
 		}
 	}
 
-loop2: // handle messages on reportChan
-	for {
-		select {
-		case i := <-g.stopChan:
-			// node i quit, we can delete it
-			delete(g.net, i)
+	// remove the dots which have reached their destination
+	g.flow.update(time.Now())
 
-		default:
-			break loop2
-		}
+	// handle the notifications from the nodes; send reports are passed
+	// to the functions registered with Subscribe in main, which set the
+	// usage trackers and spawn the dots
+	g.net.Poll()
+
+	// the statistics in the edge control panel change continuously
+	if g.ui.IsWindowOpen(edgeCtlWindow) {
+		updateEdgeStats(g)
 	}
 
 	// call ebitenui update function
@@ -180,6 +352,78 @@ loop2: // handle messages on reportChan
 
 	mx, my := ebiten.CursorPosition()
 
+	// the mouse wheel and the +/- keys zoom in and out, keeping the point
+	// under the mouse pointer still; the F key fits all nodes in the view
+	if _, wy := ebiten.Wheel(); wy != 0 {
+		g.zoomAt(math.Pow(ZOOM_STEP, wy), mx, my)
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyEqual) ||
+		inpututil.IsKeyJustPressed(ebiten.KeyKPAdd) {
+		g.zoomAt(ZOOM_STEP, mx, my)
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyMinus) ||
+		inpututil.IsKeyJustPressed(ebiten.KeyKPSubtract) {
+		g.zoomAt(1/ZOOM_STEP, mx, my)
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyF) {
+		g.fitToView()
+	}
+
+	// the L key changes what is written next to the nodes
+	if inpututil.IsKeyJustPressed(ebiten.KeyL) {
+		g.labels = g.labels.next()
+	}
+
+	// keys acting on the selected nodes: Escape clears the selection,
+	// Delete stops the selected nodes, Enter shows their control panel
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		g.clearSelection()
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyDelete) {
+		g.deleteSelection()
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyEnter) && len(g.selectedNodes()) > 0 {
+		showSelectionWindow(g)
+	}
+
+	// Ctrl+Z undoes the last edit, Ctrl+Y (or Ctrl+Shift+Z) redoes it;
+	// Ctrl+C copies the selected nodes, Ctrl+V pastes them at the mouse
+	// pointer and Ctrl+D duplicates them; Ctrl+I imports a file at the
+	// mouse pointer
+	if ebiten.IsKeyPressed(ebiten.KeyControl) {
+		shift := ebiten.IsKeyPressed(ebiten.KeyShift)
+
+		if inpututil.IsKeyJustPressed(ebiten.KeyZ) && !shift {
+			g.undo()
+		}
+
+		if inpututil.IsKeyJustPressed(ebiten.KeyY) ||
+			inpututil.IsKeyJustPressed(ebiten.KeyZ) && shift {
+			g.redo()
+		}
+
+		if inpututil.IsKeyJustPressed(ebiten.KeyC) {
+			g.copySelection()
+		}
+
+		if inpututil.IsKeyJustPressed(ebiten.KeyV) {
+			g.paste()
+		}
+
+		if inpututil.IsKeyJustPressed(ebiten.KeyD) {
+			g.duplicateSelection()
+		}
+
+		if inpututil.IsKeyJustPressed(ebiten.KeyI) {
+			promptImport(g)
+		}
+	}
+
 	if g.toolbarRect.At(mx, my) == color.Opaque {
 		// click on toolbar
 		return nil
@@ -200,7 +444,38 @@ loop2: // handle messages on reportChan
 	}
 
 	// store the coordinates of the mouse in world space
-	g.wmx, g.wmy = mx-g.xPan, my-g.yPan
+	g.wmx, g.wmy = g.toWorld(mx, my)
+
+	// with the node tool, nodes can also be dragged around holding down
+	// the left mouse button; dragging a selected node moves the whole
+	// selection. Dragging from a point without nodes draws a rubber band,
+	// which selects the nodes inside it.
+	if currentTool == TOOL_NODE {
+		if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+			g.dragNode, g.dragging = g.nodeAtMouse()
+			g.dragged = false
+
+			if g.dragging {
+				g.dragStart = g.config()
+			}
+
+			g.selecting = !g.dragging
+			g.selX, g.selY = g.wmx, g.wmy
+		}
+
+		if (g.dragging || g.selecting) && (dx != 0 || dy != 0) &&
+			ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
+
+			if g.dragging && g.selection[g.dragNode] {
+				n, _ := g.net.Node(g.dragNode)
+				g.moveSelection(g.wmx-n.X, g.wmy-n.Y)
+			} else if g.dragging {
+				g.net.Move(g.dragNode, g.wmx, g.wmy)
+			}
+
+			g.dragged = true
+		}
+	}
 
 	// the rest of the function handles left clicks
 
@@ -209,28 +484,83 @@ loop2: // handle messages on reportChan
 		return nil
 	}
 
+	shift := ebiten.IsKeyPressed(ebiten.KeyShift)
+
+	if g.selecting {
+		g.selecting = false
+
+		if g.dragged {
+			// end of the rubber band; holding shift adds to the
+			// current selection
+			g.selectInRect(g.selX, g.selY, g.wmx, g.wmy, shift)
+			return nil
+		}
+	}
+
+	if g.dragging {
+		g.dragging = false
+
+		if g.dragged {
+			// the button was released at the end of a drag,
+			// it is not a click; the whole drag is a single edit
+			g.record(g.dragStart)
+			return nil
+		}
+	}
+
+	// after "connect to" in the selection panel, a click on a node
+	// connects all the selected nodes to it, with either tool
+	if g.connectingSelection {
+		if id, ok := g.nodeAtMouse(); ok {
+			g.connectSelectionTo(id)
+		}
+
+		g.connectingSelection = false
+		return nil
+	}
+
 	switch currentTool {
 	case TOOL_NODE:
 
 		// with the node tool, the user can:
-		// - click on a node to show its control panel
-		// - click on an empty point to create a new node
-
-		if id, ok := g.nodeAtMouse(); ok {
+		// - shift-click on a node to add it to (or remove it from) the
+		//   selection
+		// - click on a node to show its control panel, or the panel of
+		//   the selection if the node is part of it
+		// - click on a channel to show its control panel
+		// - click on an empty point to clear the selection or, if no
+		//   node is selected, to create a new node
+
+		if id, ok := g.nodeAtMouse(); ok && shift {
+			g.toggleSelected(id)
+		} else if ok && g.selection[id] {
+			showSelectionWindow(g)
+		} else if ok {
 			showNodeCtlWindow(g, id)
+		} else if e, ok := g.edgeAtMouse(); ok {
+			showEdgeCtlWindow(g, e)
+		} else if len(g.selectedNodes()) > 0 {
+			g.clearSelection()
 		} else {
 			// adds a node to g.net and spawns its goroutine
-			g.net.spawnNode(g.wmx, g.wmy, g.stopChan, g.reportChan)
+			g.edit(func() { g.net.Spawn(g.wmx, g.wmy) })
 		}
 
 	case TOOL_EDGE:
 		// with the edge tool, the user can click on a node, then:
 		// - click on a non-connected node to create a new channel
 		// - click on an already connected node to remove the channel
+		// or click on a channel to show its control panel
 
 		n, ok := g.nodeAtMouse()
 
 		switch {
+		case !ok && !g.connecting:
+			// on click on a channel, show its control panel
+			if e, ok := g.edgeAtMouse(); ok {
+				showEdgeCtlWindow(g, e)
+			}
+
 		case !ok:
 			// on click on an empty point, cancel the operation
 			g.connecting = false
@@ -242,7 +572,7 @@ loop2: // handle messages on reportChan
 			// nodes if they are not the same node
 
 			if g.connectFrom != n {
-				g.net.addOrDelChan(g.connectFrom, n)
+				g.edit(func() { g.net.AddOrDelChan(g.connectFrom, n) })
 			}
 
 			g.connecting = false
@@ -277,61 +607,101 @@ func (g *Game) Draw(screen *ebiten.Image) {
 			2, color.Black, true)
 	}
 
-	// draw a line between the endpoints of each channel
-	for k, n := range g.net {
-		for j := range n.outs {
-			o := n.outs[j]
+	g.drawNetwork(screenCanvas{screen})
+
+	// the rubber band, while the user is dragging it
+	if g.selecting && g.dragged {
+		x0, y0 := g.toScreen(g.selX, g.selY)
+		x1, y1 := g.smx, g.smy
+
+		vector.StrokeRect(screen,
+			float32(min(x0, x1)), float32(min(y0, y1)),
+			float32(abs(x1-x0)), float32(abs(y1-y0)),
+			1, SELECTION_COLOR, true)
+	}
+
+	// finally, call ebitenui to draw the UI
+	g.ui.Draw(screen)
+}
 
+// Draws the network on the canvas: channels, messages, nodes and their labels,
+// as seen through the current view. Used by Draw for the screen and to export
+// the canvas as an image.
+func (g *Game) drawNetwork(cv render.Canvas) {
+	// channels in a cycle of nodes blocked sending to each other (as found
+	// by the watchdog) are highlighted
+	deadlocked := make(map[edge]bool)
+	for _, c := range g.net.Deadlocks() {
+		for i := range c {
+			deadlocked[edge{c[i], c[(i+1)%len(c)]}] = true
+		}
+	}
+
+	// draw a line between the endpoints of each channel
+	for k, n := range g.net.Nodes() {
+		for _, o := range n.Outs {
 			x0, y0 := g.nodeScreenPos(k)
-			x1, y1 := g.nodeScreenPos(o.dst)
+			x1, y1 := g.nodeScreenPos(o.Dst)
 
 			// shade of gray depends on usage
-			v := uint8(0xEE * (1 - o.usage))
-
-			// channel line
-			vector.StrokeLine(screen,
-				float32(x0), float32(y0),
-				float32(x1), float32(y1),
-				2, color.Gray{Y: v}, true)
-
-			// channels have a direction indicator, which is
-			// a triangle drawn on the line; here we compute the
-			// rotation for the triangle and draw it
-
-			a := math.Atan2(float64(y1-y0), float64(x1-x0))
-			dx, dy := float64(x1-x0), float64(y1-y0)
-			d := math.Sqrt(dx*dx + dy*dy)
-
-			drawTriangle(screen,
-				x0+int(dx/d*2*nodeSize), // center x
-				y0+int(dy/d*2*nodeSize), // center y
-				nodeSize*3/4,            // radius
-				a,                       // rotation angle
-				v,                       // color
-			)
+			v := uint8(0xEE * (1 - g.usage[edge{k, o.Dst}]))
+
+			var c color.Color = color.Gray{Y: v}
+			var width float32 = 2
+
+			if deadlocked[edge{k, o.Dst}] {
+				c = DEADLOCK_COLOR
+				width = 4
+			}
+
+			// channel line, with its direction indicator
+			render.Channel(cv, x0, y0, x1, y1, g.zoom, width, c)
 		}
 	}
 
-	// draw the nodes (on top of the channels)
+	// draw the messages travelling on the channels
+	g.drawDots(cv)
 
-	for id := range g.net {
+	// draw a border around the selected nodes, below the nodes
+	for _, id := range g.selectedNodes() {
 		x, y := g.nodeScreenPos(id)
+		s := float32(float64(render.NODE_SIZE)*g.zoom/2 + 3)
+
+		cv.StrokeRect(
+			float32(x)-s, float32(y)-s, 2*s, 2*s,
+			2, SELECTION_COLOR)
+	}
 
-		// coordinates of the top left corner of the node image
-		x = x - nodeSize/2
-		y = y - nodeSize/2
+	// draw the nodes (on top of the channels)
+	for id, n := range g.net.Nodes() {
+		x, y := g.nodeScreenPos(id)
 
-		op := ebiten.DrawImageOptions{}
-		op.GeoM.Translate(float64(x), float64(y))
+		// stalled nodes are red, paused nodes gray instead of black
+		c := render.NODE_COLOR
+		_, stalled := g.net.Stalled()[id]
 
-		// draw a different image (gray instead of black) for paused nodes
-		if g.net[id].paused {
-			screen.DrawImage(pausedNodeImage, &op)
-		} else {
-			screen.DrawImage(nodeImage, &op)
+		switch {
+		case stalled:
+			c = DEADLOCK_COLOR
+		case n.Paused:
+			c = render.PAUSED_NODE_COLOR
 		}
+
+		render.Node(cv, x, y, g.zoom, c)
+		render.Label(cv, g.nodeLabel(n), x, y, g.zoom)
 	}
+}
 
-	// finally, call ebitenui to draw the UI
-	g.ui.Draw(screen)
+// Returns the label of a node for the current label mode, e.g. "foo" or
+// "foo #3 multicast 12/40" (messages received / sent).
+func (g *Game) nodeLabel(n engine.Node) string {
+	switch g.labels {
+	case LABELS_NAME:
+		return n.Name
+	case LABELS_DETAILS:
+		return fmt.Sprintf("%s #%v %v %d/%d",
+			n.Name, n.ID, n.RelayMode, n.Received(), n.Sent())
+	default:
+		return ""
+	}
 }
@@ -0,0 +1,115 @@
+// Package render draws networks on a canvas, which is either the screen of the
+// graphical interface or an SVG image. It does not depend on the graphical
+// interface, so that images can be drawn without a display.
+package render
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+// the shapes which make up the drawing of the network, in screen coordinates
+type Canvas interface {
+	StrokeLine(x0, y0, x1, y1, width float32, c color.Color)
+	FillTriangle(x0, y0, x1, y1, x2, y2 float32, c color.Color)
+	FillRect(x, y, w, h float32, c color.Color)
+	StrokeRect(x, y, w, h, width float32, c color.Color)
+	FillCircle(x, y, r float32, c color.Color)
+
+	// a label, starting at x and vertically centered on y
+	Text(s string, x, y float32, c color.Color)
+}
+
+// size of the nodes at zoom 1
+const NODE_SIZE = 10
+
+// color of the node labels, and their distance from the border of the node
+var LABEL_COLOR = color.Gray{Y: 0x33}
+
+const LABEL_MARGIN = 4
+
+// size of the labels, smaller than the UI font
+const LABEL_FONT_SIZE = 14
+
+// color of the nodes, and of the paused ones
+var NODE_COLOR color.Color = color.Black
+var PAUSED_NODE_COLOR color.Color = color.Gray{Y: 150}
+
+// returns the Go Regular font, which is used for the UI and the labels, at the
+// given size
+func LoadFont(size float64) (font.Face, error) {
+	ttfFont, err := truetype.Parse(goregular.TTF)
+	if err != nil {
+		return nil, err
+	}
+
+	return truetype.NewFace(ttfFont, &truetype.Options{
+		Size:    size,
+		DPI:     72,
+		Hinting: font.HintingFull,
+	}), nil
+}
+
+// draws an equilateral triangle on canvas `c`, centered on coordinates `xy`,
+// with radius `r`, rotated by `a` (radians), of color `col`
+func Triangle(c Canvas, x, y, r int, a float64, col color.Color) {
+	xf, yf, rf := float32(x), float32(y), float64(r)
+	x1 := xf + float32(rf*math.Cos(a))
+	y1 := yf + float32(rf*math.Sin(a))
+	x2 := xf + float32(rf*math.Cos(a+2*math.Pi/3))
+	y2 := yf + float32(rf*math.Sin(a+2*math.Pi/3))
+	x3 := xf + float32(rf*math.Cos(a-2*math.Pi/3))
+	y3 := yf + float32(rf*math.Sin(a-2*math.Pi/3))
+
+	c.FillTriangle(x1, y1, x2, y2, x3, y3, col)
+}
+
+// Draws a channel from (x0, y0) to (x1, y1), with a triangle which shows its
+// direction; the triangle is scaled like the nodes.
+func Channel(cv Canvas, x0, y0, x1, y1 int, zoom float64, width float32, c color.Color) {
+	cv.StrokeLine(
+		float32(x0), float32(y0),
+		float32(x1), float32(y1),
+		width, c)
+
+	// the direction indicator is a triangle drawn on the line, near the
+	// source; here we compute its rotation and draw it
+
+	a := math.Atan2(float64(y1-y0), float64(x1-x0))
+	dx, dy := float64(x1-x0), float64(y1-y0)
+	d := math.Sqrt(dx*dx + dy*dy)
+
+	s := float64(NODE_SIZE) * zoom
+
+	Triangle(cv,
+		x0+int(dx/d*2*s),   // center x
+		y0+int(dy/d*2*s),   // center y
+		max(1, int(s*3/4)), // radius
+		a,                  // rotation angle
+		c,                  // color
+	)
+}
+
+// draws a node, scaled by the zoom factor and centered on (x, y)
+func Node(cv Canvas, x, y int, zoom float64, c color.Color) {
+	s := float32(float64(NODE_SIZE) * zoom)
+	cv.FillRect(float32(x)-s/2, float32(y)-s/2, s, s, c)
+}
+
+// Draws the label of a node centered on (x, y) to the right of it; the text is
+// not scaled by the zoom factor, so that it stays readable, but it follows the
+// node border.
+func Label(cv Canvas, label string, x, y int, zoom float64) {
+	if label == "" {
+		return
+	}
+
+	s := float64(NODE_SIZE) * zoom
+
+	// vertically centered on the node
+	cv.Text(label, float32(float64(x)+s/2+LABEL_MARGIN), float32(y), LABEL_COLOR)
+}
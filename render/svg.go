@@ -0,0 +1,154 @@
+package render
+
+import (
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"network-manager/engine"
+
+	"golang.org/x/image/font"
+)
+
+// writes the shapes as SVG elements
+type SVG struct {
+	W io.Writer
+}
+
+// formats a coordinate, without useless decimals
+func svgNum(f float32) string {
+	return strconv.FormatFloat(float64(f), 'f', -1, 32)
+}
+
+// Returns the attributes which paint with c, e.g. fill="#ff0000"; attr is
+// "fill" or "stroke". Transparent colors get an opacity attribute too.
+func svgPaint(attr string, c color.Color) string {
+	r, g, b, a := c.RGBA()
+	if a == 0 {
+		return attr + `="none"`
+	}
+
+	// the components are premultiplied by alpha
+	paint := fmt.Sprintf(`%s="#%02x%02x%02x"`, attr,
+		r*0xff/a, g*0xff/a, b*0xff/a)
+
+	if a != 0xffff {
+		paint += fmt.Sprintf(` %s-opacity="%.3f"`, attr, float64(a)/0xffff)
+	}
+
+	return paint
+}
+
+func (s SVG) StrokeLine(x0, y0, x1, y1, width float32, c color.Color) {
+	fmt.Fprintf(s.W, "<line x1=\"%s\" y1=\"%s\" x2=\"%s\" y2=\"%s\" stroke-width=\"%s\" %s/>\n",
+		svgNum(x0), svgNum(y0), svgNum(x1), svgNum(y1), svgNum(width), svgPaint("stroke", c))
+}
+
+func (s SVG) FillTriangle(x0, y0, x1, y1, x2, y2 float32, c color.Color) {
+	fmt.Fprintf(s.W, "<polygon points=\"%s,%s %s,%s %s,%s\" %s/>\n",
+		svgNum(x0), svgNum(y0), svgNum(x1), svgNum(y1), svgNum(x2), svgNum(y2),
+		svgPaint("fill", c))
+}
+
+func (s SVG) FillRect(x, y, w, h float32, c color.Color) {
+	fmt.Fprintf(s.W, "<rect x=\"%s\" y=\"%s\" width=\"%s\" height=\"%s\" %s/>\n",
+		svgNum(x), svgNum(y), svgNum(w), svgNum(h), svgPaint("fill", c))
+}
+
+func (s SVG) StrokeRect(x, y, w, h, width float32, c color.Color) {
+	fmt.Fprintf(s.W, "<rect x=\"%s\" y=\"%s\" width=\"%s\" height=\"%s\" fill=\"none\" stroke-width=\"%s\" %s/>\n",
+		svgNum(x), svgNum(y), svgNum(w), svgNum(h), svgNum(width), svgPaint("stroke", c))
+}
+
+func (s SVG) FillCircle(x, y, r float32, c color.Color) {
+	fmt.Fprintf(s.W, "<circle cx=\"%s\" cy=\"%s\" r=\"%s\" %s/>\n",
+		svgNum(x), svgNum(y), svgNum(r), svgPaint("fill", c))
+}
+
+// the labels use the same font as the screen, Go Regular, if the viewer has it
+func (s SVG) Text(str string, x, y float32, c color.Color) {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(str))
+
+	fmt.Fprintf(s.W, "<text x=\"%s\" y=\"%s\" font-family=\"Go, sans-serif\" font-size=\"%d\" "+
+		"dominant-baseline=\"central\" xml:space=\"preserve\" %s>%s</text>\n",
+		svgNum(x), svgNum(y), LABEL_FONT_SIZE, svgPaint("fill", c), b.String())
+}
+
+// writes an SVG image of the given size, with a white background, whose
+// contents are drawn by draw
+func WriteSVG(w io.Writer, width, height int, draw func(Canvas)) error {
+	fmt.Fprintf(w, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\">\n",
+		width, height, width, height)
+	fmt.Fprintln(w, `<rect width="100%" height="100%" fill="white"/>`)
+
+	draw(SVG{w})
+
+	_, err := fmt.Fprintln(w, "</svg>")
+
+	return err
+}
+
+// space left around the nodes in the images of networks which are not running
+const SVG_MARGIN = 30
+
+// color of the channels which have not been used, as they are in the window
+var IDLE_CHANNEL_COLOR = color.Gray{Y: 0xEE}
+
+// Writes the nodes of a network which is not running (e.g. read with
+// engine.ReadNodes) as an SVG image, as the window would show them: at their
+// positions with zoom 1, labeled with their names. The image is just large
+// enough for all of them and their labels.
+func WriteNetworkSVG(w io.Writer, nodes []engine.Node) error {
+	face, err := LoadFont(LABEL_FONT_SIZE)
+	if err != nil {
+		return err
+	}
+
+	// bounding box of the nodes and their labels
+	x0, y0 := math.MaxInt, math.MaxInt
+	x1, y1 := math.MinInt, math.MinInt
+
+	pos := make(map[engine.NodeID]engine.Node, len(nodes))
+
+	for _, n := range nodes {
+		label := NODE_SIZE/2 + LABEL_MARGIN + font.MeasureString(face, n.Name).Ceil()
+
+		x0, y0 = min(x0, n.X), min(y0, n.Y)
+		x1, y1 = max(x1, n.X+label), max(y1, n.Y)
+
+		pos[n.ID] = n
+	}
+
+	if x0 > x1 {
+		// no nodes
+		x0, y0, x1, y1 = 0, 0, 0, 0
+	}
+
+	// from world to image coordinates
+	dx, dy := SVG_MARGIN-x0, SVG_MARGIN-y0
+
+	return WriteSVG(w, x1-x0+2*SVG_MARGIN, y1-y0+2*SVG_MARGIN, func(cv Canvas) {
+		for _, n := range nodes {
+			for _, o := range n.Outs {
+				d := pos[o.Dst]
+				Channel(cv, n.X+dx, n.Y+dy, d.X+dx, d.Y+dy, 1, 2, IDLE_CHANNEL_COLOR)
+			}
+		}
+
+		// the nodes on top of the channels
+		for _, n := range nodes {
+			c := NODE_COLOR
+			if n.Paused {
+				c = PAUSED_NODE_COLOR
+			}
+
+			Node(cv, n.X+dx, n.Y+dy, 1, c)
+			Label(cv, n.Name, n.X+dx, n.Y+dy, 1)
+		}
+	})
+}
@@ -0,0 +1,120 @@
+// This file implements the selection of several nodes at once, with a rubber
+// band or with shift-clicks, and the operations applied to all of them.
+
+package main
+
+import (
+	"slices"
+	"time"
+
+	"network-manager/engine"
+)
+
+// returns the IDs of the selected nodes, sorted; nodes which have been stopped
+// in the meantime are removed from the selection
+func (g *Game) selectedNodes() []engine.NodeID {
+	ids := make([]engine.NodeID, 0, len(g.selection))
+
+	for id := range g.selection {
+		if n, ok := g.net.Node(id); ok && !n.Stopped() {
+			ids = append(ids, id)
+		} else {
+			delete(g.selection, id)
+		}
+	}
+
+	slices.Sort(ids)
+
+	return ids
+}
+
+// adds a node to the selection, or removes it if it was already selected
+func (g *Game) toggleSelected(id engine.NodeID) {
+	if g.selection[id] {
+		delete(g.selection, id)
+	} else {
+		g.selection[id] = true
+	}
+}
+
+func (g *Game) clearSelection() {
+	clear(g.selection)
+	g.connectingSelection = false
+}
+
+// Selects the nodes inside the rectangle with corners (x0, y0) and (x1, y1),
+// in world coordinates. If add is false, the previous selection is replaced.
+func (g *Game) selectInRect(x0, y0, x1, y1 int, add bool) {
+	if !add {
+		clear(g.selection)
+	}
+
+	x0, x1 = min(x0, x1), max(x0, x1)
+	y0, y1 = min(y0, y1), max(y0, y1)
+
+	for id, n := range g.net.Nodes() {
+		if n.X >= x0 && n.X <= x1 && n.Y >= y0 && n.Y <= y1 {
+			g.selection[id] = true
+		}
+	}
+}
+
+// Moves all the selected nodes by (dx, dy), in world coordinates. Called while
+// dragging, the move is recorded in the history when the drag ends.
+func (g *Game) moveSelection(dx, dy int) {
+	for _, id := range g.selectedNodes() {
+		n, _ := g.net.Node(id)
+		g.net.Move(id, n.X+dx, n.Y+dy)
+	}
+}
+
+// pauses (or resumes) all the selected nodes; since the engine can only toggle
+// the state of a node, nodes which are already paused (or running) are skipped
+func (g *Game) pauseSelection(pause bool) {
+	g.edit(func() {
+		for _, id := range g.selectedNodes() {
+			if n, _ := g.net.Node(id); n.Paused != pause {
+				g.net.TogglePause(id)
+			}
+		}
+	})
+}
+
+// stops all the selected nodes and clears the selection
+func (g *Game) deleteSelection() {
+	g.edit(func() {
+		for _, id := range g.selectedNodes() {
+			g.net.Stop(id)
+		}
+	})
+
+	g.clearSelection()
+}
+
+func (g *Game) setSelectionRelayMode(mode engine.RelayMode) {
+	g.edit(func() {
+		for _, id := range g.selectedNodes() {
+			g.net.SetRelayMode(id, mode)
+		}
+	})
+}
+
+func (g *Game) setSelectionSendInterval(d time.Duration) {
+	g.edit(func() {
+		for _, id := range g.selectedNodes() {
+			g.net.SetSendInterval(id, d)
+		}
+	})
+}
+
+// Adds a channel from every selected node to dst. Existing channels are kept,
+// and dst is not connected to itself if it is part of the selection.
+func (g *Game) connectSelectionTo(dst engine.NodeID) {
+	g.edit(func() {
+		for _, id := range g.selectedNodes() {
+			if id != dst {
+				g.net.Connect(id, dst)
+			}
+		}
+	})
+}
@@ -5,40 +5,55 @@
 package main
 
 import (
+	"fmt"
 	go_image "image"
 	"image/color"
 
 	"strconv"
+	"time"
+
+	"network-manager/engine"
 
 	"github.com/ebitenui/ebitenui"
 	"github.com/ebitenui/ebitenui/image"
 	"github.com/ebitenui/ebitenui/widget"
-	"github.com/golang/freetype/truetype"
-	"github.com/hajimehoshi/ebiten/v2"
 	"golang.org/x/image/font"
-	"golang.org/x/image/font/gofont/goregular"
 	"os"
 )
 
 var buttonImage *widget.ButtonImage
 
-const nodeSize = 10
-
-var nodeImage *ebiten.Image
-var pausedNodeImage *ebiten.Image
-
 var face font.Face
 
 var pathSelectWindow *widget.Window
 var pathSelectHandler func(string)
+var pathSelectHint *widget.Text
 var nodeCtlWindow *widget.Window
 var nameInput *widget.TextInput
 var sendTextInput *widget.TextInput
 var sendIntervalInput *widget.TextInput
+var ttlInput *widget.TextInput
 var relayModeRadioGroup *widget.RadioGroup
 var roundRobinBtn *widget.Button
 var multicastBtn *widget.Button
+var overflowRadioGroup *widget.RadioGroup
+var overflowBtns []*widget.Button // indexed by engine.OverflowPolicy
+var overflowTimeoutInput *widget.TextInput
 var pauseBtnLabel *string
+var nodeStatsText *widget.Text
+var edgeCtlWindow *widget.Window
+var edgeTitleText *widget.Text
+var edgeStatsText *widget.Text
+var edgeOverflowRadioGroup *widget.RadioGroup
+var edgeOverflowBtns []*widget.Button // indexed by engine.OverflowPolicy
+var edgeOverflowTimeoutInput *widget.TextInput
+var bufSizeInput *widget.TextInput
+var delayInput *widget.TextInput
+var jitterInput *widget.TextInput
+var lossInput *widget.TextInput
+var selectionWindow *widget.Window
+var selectionTitleText *widget.Text
+var selectionIntervalInput *widget.TextInput
 var errPopUpWindow *widget.Window
 var errPopUpText *widget.Text
 
@@ -58,19 +73,6 @@ func loadButtonImage() *widget.ButtonImage {
 	}
 }
 
-func loadFont(size float64) (font.Face, error) {
-	ttfFont, err := truetype.Parse(goregular.TTF)
-	if err != nil {
-		return nil, err
-	}
-
-	return truetype.NewFace(ttfFont, &truetype.Options{
-		Size:    size,
-		DPI:     72,
-		Hinting: font.HintingFull,
-	}), nil
-}
-
 func addButton(
 	container *widget.Container,
 	text string,
@@ -126,46 +128,69 @@ func makeToolbarWindow(g *Game) (*widget.Window, go_image.Rectangle) {
 		widget.RadioGroupOpts.InitialElement(nodeToolBtn),
 	)
 
+	addButton(toolbar, "fit", func(args *widget.ButtonClickedEventArgs) {
+		g.fitToView()
+	})
+
+	addButton(toolbar, "labels", func(args *widget.ButtonClickedEventArgs) {
+		g.labels = g.labels.next()
+	})
+
 	addButton(toolbar, "save", func(args *widget.ButtonClickedEventArgs) {
-		promptPath(g, func(p string) {
+		promptPath(g, NETWORK_FORMATS, func(p string) {
 			f, err := os.Create(p)
 			if err != nil {
 				errPopUp(g, "Couldn't create file")
 				return
 			}
 
-			g.net.serialize(f)
+			// the format is chosen by the extension of the file
+			if err := g.net.Serialize(f, engine.CodecFor(p)); err != nil {
+				errPopUp(g, "Couldn't write file")
+			}
 
 			f.Close()
 		})
 	})
 
 	addButton(toolbar, "load", func(args *widget.ButtonClickedEventArgs) {
-		promptPath(g, func(p string) {
+		promptPath(g, NETWORK_FORMATS, func(p string) {
 			f, err := os.Open(p)
 			if err != nil {
 				errPopUp(g, "Couldn't open file")
 				return
 			}
 
-			g.net.stopAllAndWait(g.stopChan)
+			// loading can be undone, like any other edit; the running
+			// nodes are only replaced if the file is valid
+			g.edit(func() {
+				err = g.net.Deserialize(f, engine.CodecFor(p))
+			})
 
-			net, maxid := deserialize(f, g.stopChan, g.reportChan)
-			if net == nil {
-				errPopUp(g, "Error during parsing")
-				return
+			f.Close()
+
+			if err != nil {
+				parseErrPopUp(g, err)
 			}
+		})
+	})
 
-			g.net = net
-			nextNodeId = int(maxid) + 1
+	// adds a saved network to the current one, see promptImport
+	addButton(toolbar, "import", func(args *widget.ButtonClickedEventArgs) {
+		promptImport(g)
+	})
 
-			f.Close()
+	// saves what is shown on the canvas, without the UI
+	addButton(toolbar, "image", func(args *widget.ButtonClickedEventArgs) {
+		promptPath(g, IMAGE_FORMATS, func(p string) {
+			if err := g.exportImage(p); err != nil {
+				errPopUp(g, "Couldn't write image")
+			}
 		})
 	})
 
 	addButton(toolbar, "clear", func(args *widget.ButtonClickedEventArgs) {
-		g.net.stopAllAndWait(g.stopChan)
-		nextNodeId = 0
+		g.edit(g.net.StopAllAndWait)
 	})
 
 	w := widget.NewWindow(widget.WindowOpts.Contents(toolbar))
@@ -191,7 +216,7 @@ func makeErrWindow() {
 
 	errPopUpText = widget.NewText(
 		widget.TextOpts.Text("                                   ", face, color.White),
-		widget.TextOpts.Position(widget.TextPositionCenter, widget.TextPositionCenter),
+		widget.TextOpts.Position(widget.TextPositionStart, widget.TextPositionCenter),
 		widget.TextOpts.WidgetOpts(
 			widget.WidgetOpts.LayoutData(widget.RowLayoutData{
 				Position: widget.RowLayoutPositionCenter,
@@ -214,15 +239,90 @@ func makeErrWindow() {
 
 func errPopUp(g *Game, text string) {
 	errPopUpText.Label = text
+
+	// the text can span several lines
+	x, y := errPopUpWindow.Contents.PreferredSize()
+	r := go_image.Rect(0, 0, x, y)
+	r = r.Add(go_image.Point{50, 100})
+	errPopUpWindow.SetLocation(r)
+
 	g.ui.AddWindow(errPopUpWindow)
 }
 
-func addRelayModeBtn(g *Game, container *widget.Container, text string, mode relaymode) *widget.Button {
+// shows the errors found while reading a network, one per line
+func parseErrPopUp(g *Game, err error) {
+	fmt.Fprintf(os.Stderr, "deserialization error:\n%v\n", err)
+	errPopUp(g, "Error during parsing:\n"+err.Error())
+}
+
+func addRelayModeBtn(g *Game, container *widget.Container, text string, mode engine.RelayMode) *widget.Button {
 	return addButton(container, text, func(args *widget.ButtonClickedEventArgs) {
-		g.net.setRelayMode(g.selectedNode, mode)
+		g.edit(func() { g.net.SetRelayMode(g.selectedNode, mode) })
 	})
 }
 
+// The overflow policy is set per channel by the engine, but the node control
+// panel sets the same policy for all the output channels of the node when
+// one of its overflow widgets is changed. Those are the values currently
+// shown in the panel.
+var nodeOverflow engine.OverflowPolicy
+var nodeOverflowTimeout time.Duration
+
+func setNodeOverflow(g *Game, policy engine.OverflowPolicy, timeout time.Duration) {
+	nodeOverflow, nodeOverflowTimeout = policy, timeout
+
+	g.edit(func() {
+		n, _ := g.net.Node(g.selectedNode)
+		for _, o := range n.Outs {
+			g.net.SetOverflow(g.selectedNode, o.Dst, policy, timeout)
+		}
+	})
+}
+
+// Adds a row of buttons to select the overflow policy of channels, which call
+// handler when clicked. Returns the radio group of the buttons and the buttons,
+// indexed by policy.
+func addOverflowRow(
+	container *widget.Container,
+	handler func(engine.OverflowPolicy),
+) (*widget.RadioGroup, []*widget.Button) {
+	overflowLabel := newLabel("On full channel: ")
+
+	overflowRow := widget.NewContainer(
+		widget.ContainerOpts.Layout(widget.NewRowLayout(
+			widget.RowLayoutOpts.Direction(widget.DirectionHorizontal),
+		)),
+	)
+
+	overflowRow.AddChild(overflowLabel)
+
+	addOverflowBtn := func(text string, policy engine.OverflowPolicy) *widget.Button {
+		return addButton(overflowRow, text, func(args *widget.ButtonClickedEventArgs) {
+			handler(policy)
+		})
+	}
+
+	btns := []*widget.Button{
+		engine.BLOCK:         addOverflowBtn("block", engine.BLOCK),
+		engine.DROP_NEWEST:   addOverflowBtn("drop newest", engine.DROP_NEWEST),
+		engine.DROP_OLDEST:   addOverflowBtn("drop oldest", engine.DROP_OLDEST),
+		engine.BLOCK_TIMEOUT: addOverflowBtn("timeout", engine.BLOCK_TIMEOUT),
+	}
+
+	rg := widget.NewRadioGroup(
+		widget.RadioGroupOpts.Elements(
+			btns[engine.BLOCK],
+			btns[engine.DROP_NEWEST],
+			btns[engine.DROP_OLDEST],
+			btns[engine.BLOCK_TIMEOUT],
+		),
+	)
+
+	container.AddChild(overflowRow)
+
+	return rg, btns
+}
+
 func addTextInput(
 	container *widget.Container,
 	label string,
@@ -299,7 +399,7 @@ func makeNodeCtlWindow(g *Game) {
 		NO_VALIDATOR,
 
 		func(args *widget.TextInputChangedEventArgs) {
-			g.net.setName(g.selectedNode, args.InputText)
+			g.edit(func() { g.net.SetName(g.selectedNode, args.InputText) })
 		},
 
 		false)
@@ -308,21 +408,32 @@ func makeNodeCtlWindow(g *Game) {
 		NO_VALIDATOR,
 
 		func(args *widget.TextInputChangedEventArgs) {
-			g.net.setSendText(g.selectedNode, args.InputText)
+			g.edit(func() { g.net.SetSendText(g.selectedNode, args.InputText) })
 		},
 
 		false)
 
 	sendIntervalInput = addTextInput(container, "Send interval (ms)",
+		nonNegativeIntValidator,
+
+		func(args *widget.TextInputChangedEventArgs) {
+			ms, _ := strconv.Atoi(args.InputText)
+			g.edit(func() {
+				g.net.SetSendInterval(g.selectedNode, time.Duration(ms)*time.Millisecond)
+			})
+		},
+
+		false)
+
+	ttlInput = addTextInput(container, "TTL (0 = no limit)",
 		func(input string) (bool, *string) {
-			// _, err := strconv.ParseFloat(input, 64)
-			_, err := strconv.Atoi(input)
-			return err == nil, nil
+			ttl, err := strconv.Atoi(input)
+			return err == nil && ttl >= 0, nil
 		},
 
 		func(args *widget.TextInputChangedEventArgs) {
-			ms, _ := strconv.Atoi(args.InputText)
-			g.net.setSendInterval(g.selectedNode, ms)
+			ttl, _ := strconv.Atoi(args.InputText)
+			g.edit(func() { g.net.SetTTL(g.selectedNode, ttl) })
 		},
 
 		false)
@@ -350,9 +461,9 @@ func makeNodeCtlWindow(g *Game) {
 
 	relayModeRow.AddChild(relayModeLabel)
 
-	roundRobinBtn = addRelayModeBtn(g, relayModeRow, "round-robin", ROUND_ROBIN)
-	multicastBtn = addRelayModeBtn(g, relayModeRow, "multicast", MULTICAST)
-	multicastBtn = addRelayModeBtn(g, relayModeRow, "discard", DISCARD)
+	roundRobinBtn = addRelayModeBtn(g, relayModeRow, "round-robin", engine.ROUND_ROBIN)
+	multicastBtn = addRelayModeBtn(g, relayModeRow, "multicast", engine.MULTICAST)
+	multicastBtn = addRelayModeBtn(g, relayModeRow, "discard", engine.DISCARD)
 
 	relayModeRadioGroup = widget.NewRadioGroup(
 		widget.RadioGroupOpts.Elements(roundRobinBtn, multicastBtn),
@@ -360,6 +471,32 @@ func makeNodeCtlWindow(g *Game) {
 
 	container.AddChild(relayModeRow)
 
+	overflowRadioGroup, overflowBtns = addOverflowRow(container,
+		func(policy engine.OverflowPolicy) {
+			setNodeOverflow(g, policy, nodeOverflowTimeout)
+		})
+
+	overflowTimeoutInput = addTextInput(container, "Overflow timeout (ms)",
+		nonNegativeIntValidator,
+
+		func(args *widget.TextInputChangedEventArgs) {
+			ms, _ := strconv.Atoi(args.InputText)
+			timeout := time.Duration(ms) * time.Millisecond
+
+			// "apply" submits this input too: only overwrite the
+			// policies of the channels if the timeout was changed,
+			// so that those set in the channel panels are kept
+			if timeout != nodeOverflowTimeout {
+				setNodeOverflow(g, nodeOverflow, timeout)
+			}
+		},
+
+		false)
+
+	nodeStatsText = newLabel("Expired: 0, dropped on full channels: 0")
+
+	container.AddChild(nodeStatsText)
+
 	buttonsRow := widget.NewContainer(
 		widget.ContainerOpts.Layout(widget.NewRowLayout(
 			widget.RowLayoutOpts.Direction(widget.DirectionHorizontal),
@@ -369,7 +506,10 @@ func makeNodeCtlWindow(g *Game) {
 	)
 
 	pauseBtnLabel = &addButton(buttonsRow, "pause", func(args *widget.ButtonClickedEventArgs) {
-		if g.net.togglePause(g.selectedNode) {
+		paused := false
+		g.edit(func() { paused = g.net.TogglePause(g.selectedNode) })
+
+		if paused {
 			*pauseBtnLabel = "resume"
 		} else {
 			*pauseBtnLabel = " pause "
@@ -377,7 +517,7 @@ func makeNodeCtlWindow(g *Game) {
 	}).Text().Label
 
 	addButton(buttonsRow, "delete", func(args *widget.ButtonClickedEventArgs) {
-		g.net.stop(g.selectedNode)
+		g.edit(func() { g.net.Stop(g.selectedNode) })
 		nodeCtlWindow.Close()
 	})
 
@@ -389,6 +529,8 @@ func makeNodeCtlWindow(g *Game) {
 		nameInput.Submit()
 		sendTextInput.Submit()
 		sendIntervalInput.Submit()
+		ttlInput.Submit()
+		overflowTimeoutInput.Submit()
 		nodeCtlWindow.Close()
 	})
 
@@ -401,6 +543,286 @@ func makeNodeCtlWindow(g *Game) {
 	)
 }
 
+// returns the information about the selected edge
+// the second return value is false if the edge does not exist anymore
+func selectedChan(g *Game) (engine.ChanInfo, bool) {
+	n, _ := g.net.Node(g.selectedEdge.src)
+	for _, o := range n.Outs {
+		if o.Dst == g.selectedEdge.dst {
+			return o, true
+		}
+	}
+
+	return engine.ChanInfo{}, false
+}
+
+// changes one of the link parameters of the selected edge
+func setSelectedLink(g *Game, set func(p *engine.LinkParams)) {
+	if o, ok := selectedChan(g); ok {
+		p := o.Link
+		set(&p)
+		g.edit(func() { g.net.SetLink(g.selectedEdge.src, g.selectedEdge.dst, p) })
+	}
+}
+
+// changes the overflow policy of the selected edge
+func setSelectedOverflow(g *Game, policy engine.OverflowPolicy, timeout time.Duration) {
+	g.edit(func() {
+		g.net.SetOverflow(g.selectedEdge.src, g.selectedEdge.dst, policy, timeout)
+	})
+}
+
+func newLabel(text string) *widget.Text {
+	return widget.NewText(
+		widget.TextOpts.Text(text, face, color.White),
+		widget.TextOpts.Position(widget.TextPositionCenter, widget.TextPositionCenter),
+		widget.TextOpts.WidgetOpts(
+			widget.WidgetOpts.LayoutData(widget.RowLayoutData{
+				Position: widget.RowLayoutPositionCenter,
+			}),
+		),
+	)
+}
+
+func nonNegativeIntValidator(input string) (bool, *string) {
+	n, err := strconv.Atoi(input)
+	return err == nil && n >= 0, nil
+}
+
+func makeEdgeCtlWindow(g *Game) {
+	container := widget.NewContainer(
+		widget.ContainerOpts.BackgroundImage(
+			image.NewNineSliceColor(color.Black)),
+
+		widget.ContainerOpts.Layout(widget.NewRowLayout(
+			widget.RowLayoutOpts.Direction(widget.DirectionVertical),
+			widget.RowLayoutOpts.Padding(widget.NewInsetsSimple(5)),
+			widget.RowLayoutOpts.Spacing(10),
+		)),
+	)
+
+	edgeTitleText = newLabel("Channel")
+	container.AddChild(edgeTitleText)
+
+	edgeStatsText = newLabel("Messages: 0")
+	container.AddChild(edgeStatsText)
+
+	bufSizeInput = addTextInput(container, "Buffer size",
+		nonNegativeIntValidator,
+
+		func(args *widget.TextInputChangedEventArgs) {
+			size, _ := strconv.Atoi(args.InputText)
+			setSelectedLink(g, func(p *engine.LinkParams) {
+				p.BufSize = size
+			})
+		},
+
+		false)
+
+	delayInput = addTextInput(container, "Delay (ms)",
+		nonNegativeIntValidator,
+
+		func(args *widget.TextInputChangedEventArgs) {
+			ms, _ := strconv.Atoi(args.InputText)
+			setSelectedLink(g, func(p *engine.LinkParams) {
+				p.Delay = time.Duration(ms) * time.Millisecond
+			})
+		},
+
+		false)
+
+	jitterInput = addTextInput(container, "Jitter (ms)",
+		nonNegativeIntValidator,
+
+		func(args *widget.TextInputChangedEventArgs) {
+			ms, _ := strconv.Atoi(args.InputText)
+			setSelectedLink(g, func(p *engine.LinkParams) {
+				p.Jitter = time.Duration(ms) * time.Millisecond
+			})
+		},
+
+		false)
+
+	lossInput = addTextInput(container, "Loss probability",
+		func(input string) (bool, *string) {
+			loss, err := strconv.ParseFloat(input, 64)
+			return err == nil && loss >= 0 && loss <= 1, nil
+		},
+
+		func(args *widget.TextInputChangedEventArgs) {
+			loss, _ := strconv.ParseFloat(args.InputText, 64)
+			setSelectedLink(g, func(p *engine.LinkParams) {
+				p.Loss = loss
+			})
+		},
+
+		false)
+
+	edgeOverflowRadioGroup, edgeOverflowBtns = addOverflowRow(container,
+		func(policy engine.OverflowPolicy) {
+			o, _ := selectedChan(g)
+			setSelectedOverflow(g, policy, o.Timeout)
+		})
+
+	edgeOverflowTimeoutInput = addTextInput(container, "Overflow timeout (ms)",
+		nonNegativeIntValidator,
+
+		func(args *widget.TextInputChangedEventArgs) {
+			ms, _ := strconv.Atoi(args.InputText)
+			o, _ := selectedChan(g)
+			setSelectedOverflow(g, o.Overflow, time.Duration(ms)*time.Millisecond)
+		},
+
+		false)
+
+	buttonsRow := widget.NewContainer(
+		widget.ContainerOpts.Layout(widget.NewRowLayout(
+			widget.RowLayoutOpts.Direction(widget.DirectionHorizontal),
+			widget.RowLayoutOpts.Spacing(5),
+		)),
+	)
+
+	addButton(buttonsRow, "delete", func(args *widget.ButtonClickedEventArgs) {
+		g.edit(func() { g.net.Disconnect(g.selectedEdge.src, g.selectedEdge.dst) })
+		edgeCtlWindow.Close()
+	})
+
+	addButton(buttonsRow, "cancel", func(args *widget.ButtonClickedEventArgs) {
+		edgeCtlWindow.Close()
+	})
+
+	addButton(buttonsRow, "apply", func(args *widget.ButtonClickedEventArgs) {
+		bufSizeInput.Submit()
+		delayInput.Submit()
+		jitterInput.Submit()
+		lossInput.Submit()
+		edgeOverflowTimeoutInput.Submit()
+		edgeCtlWindow.Close()
+	})
+
+	container.AddChild(buttonsRow)
+
+	edgeCtlWindow = widget.NewWindow(
+		widget.WindowOpts.Contents(container),
+		widget.WindowOpts.CloseMode(widget.NONE),
+		widget.WindowOpts.Modal(),
+	)
+}
+
+// The selection panel applies the same change to all the selected nodes. Unlike
+// the node control panel, it does not show the current values, since they can
+// be different for each node.
+func makeSelectionWindow(g *Game) {
+	container := widget.NewContainer(
+		widget.ContainerOpts.BackgroundImage(
+			image.NewNineSliceColor(color.Black)),
+
+		widget.ContainerOpts.Layout(widget.NewRowLayout(
+			widget.RowLayoutOpts.Direction(widget.DirectionVertical),
+			widget.RowLayoutOpts.Padding(widget.NewInsetsSimple(5)),
+			widget.RowLayoutOpts.Spacing(10),
+		)),
+	)
+
+	selectionTitleText = newLabel("0 nodes selected")
+	container.AddChild(selectionTitleText)
+
+	selectionIntervalInput = addTextInput(container, "Send interval (ms)",
+		nonNegativeIntValidator,
+
+		func(args *widget.TextInputChangedEventArgs) {
+			// left empty, the intervals are not changed
+			ms, err := strconv.Atoi(args.InputText)
+			if err == nil {
+				g.setSelectionSendInterval(time.Duration(ms) * time.Millisecond)
+			}
+		},
+
+		true)
+
+	relayModeRow := widget.NewContainer(
+		widget.ContainerOpts.Layout(widget.NewRowLayout(
+			widget.RowLayoutOpts.Direction(widget.DirectionHorizontal),
+			widget.RowLayoutOpts.Spacing(5),
+		)),
+	)
+
+	relayModeRow.AddChild(newLabel("Relay mode: "))
+
+	addSelectionRelayModeBtn := func(text string, mode engine.RelayMode) {
+		addButton(relayModeRow, text, func(args *widget.ButtonClickedEventArgs) {
+			g.setSelectionRelayMode(mode)
+		})
+	}
+
+	addSelectionRelayModeBtn("round-robin", engine.ROUND_ROBIN)
+	addSelectionRelayModeBtn("multicast", engine.MULTICAST)
+	addSelectionRelayModeBtn("discard", engine.DISCARD)
+
+	container.AddChild(relayModeRow)
+
+	buttonsRow := widget.NewContainer(
+		widget.ContainerOpts.Layout(widget.NewRowLayout(
+			widget.RowLayoutOpts.Direction(widget.DirectionHorizontal),
+			widget.RowLayoutOpts.Spacing(5),
+		)),
+	)
+
+	addButton(buttonsRow, "pause", func(args *widget.ButtonClickedEventArgs) {
+		g.pauseSelection(true)
+	})
+
+	addButton(buttonsRow, "resume", func(args *widget.ButtonClickedEventArgs) {
+		g.pauseSelection(false)
+	})
+
+	// the next click on a node connects the selection to it
+	addButton(buttonsRow, "connect to...", func(args *widget.ButtonClickedEventArgs) {
+		g.connectingSelection = true
+		selectionWindow.Close()
+	})
+
+	addButton(buttonsRow, "delete", func(args *widget.ButtonClickedEventArgs) {
+		g.deleteSelection()
+		selectionWindow.Close()
+	})
+
+	addButton(buttonsRow, "cancel", func(args *widget.ButtonClickedEventArgs) {
+		selectionWindow.Close()
+	})
+
+	addButton(buttonsRow, "apply", func(args *widget.ButtonClickedEventArgs) {
+		selectionIntervalInput.Submit()
+		selectionWindow.Close()
+	})
+
+	container.AddChild(buttonsRow)
+
+	selectionWindow = widget.NewWindow(
+		widget.WindowOpts.Contents(container),
+		widget.WindowOpts.CloseMode(widget.NONE),
+		widget.WindowOpts.Modal(),
+	)
+}
+
+func showSelectionWindow(g *Game) {
+	selectionTitleText.Label = fmt.Sprintf("%d nodes selected",
+		len(g.selectedNodes()))
+
+	selectionIntervalInput.SetText("")
+
+	rw, rh := selectionWindow.Contents.PreferredSize()
+	r := go_image.Rect(0, 0, rw, rh)
+	r = r.Add(go_image.Point{g.smx, g.smy})
+	selectionWindow.SetLocation(r)
+
+	g.ui.AddWindow(selectionWindow)
+}
+
+// the file formats listed by the path selection window
+const NETWORK_FORMATS = "Formats: .dot, .json; save only: .graphml, .mmd"
+const IMAGE_FORMATS = "Formats: .png, .svg"
+
 func makePathSelectWindow() {
 	container := widget.NewContainer(
 		widget.ContainerOpts.BackgroundImage(
@@ -413,6 +835,11 @@ func makePathSelectWindow() {
 		)),
 	)
 
+	// the format of the file is chosen by its extension, the hint lists the
+	// supported ones
+	pathSelectHint = newLabel(NETWORK_FORMATS)
+	container.AddChild(pathSelectHint)
+
 	pathInput := addTextInput(container, "Path", NO_VALIDATOR,
 		func(args *widget.TextInputChangedEventArgs) {
 			pathSelectHandler(args.InputText)
@@ -452,24 +879,47 @@ func makePathSelectWindow() {
 	pathSelectWindow.SetLocation(r)
 }
 
-func showNodeCtlWindow(g *Game, id nodeid) {
+func showNodeCtlWindow(g *Game, id engine.NodeID) {
 	g.selectedNode = id
 
-	nameInput.SetText(g.net[g.selectedNode].name)
-	sendTextInput.SetText(g.net[g.selectedNode].sendText)
+	n, _ := g.net.Node(id)
+
+	nameInput.SetText(n.Name)
+	sendTextInput.SetText(n.SendText)
 	sendIntervalInput.SetText(
 		strconv.FormatInt(
-			g.net[g.selectedNode].sendInterval.Milliseconds(),
+			n.SendInterval.Milliseconds(),
 			10))
 
-	switch g.net[id].relayMode {
-	case ROUND_ROBIN:
+	ttlInput.SetText(strconv.Itoa(n.TTL))
+
+	// the panel shows the overflow policy of the first output channel,
+	// since it normally is the same for all of them
+	nodeOverflow, nodeOverflowTimeout = engine.BLOCK, 0
+	if len(n.Outs) > 0 {
+		nodeOverflow, nodeOverflowTimeout = n.Outs[0].Overflow, n.Outs[0].Timeout
+	}
+
+	overflowRadioGroup.SetActive(overflowBtns[nodeOverflow])
+	overflowTimeoutInput.SetText(
+		strconv.FormatInt(nodeOverflowTimeout.Milliseconds(), 10))
+
+	dropped := 0
+	for _, o := range n.Outs {
+		dropped += o.Dropped
+	}
+
+	nodeStatsText.Label = "Expired: " + strconv.Itoa(n.Expired) +
+		", dropped on full channels: " + strconv.Itoa(dropped)
+
+	switch n.RelayMode {
+	case engine.ROUND_ROBIN:
 		relayModeRadioGroup.SetActive(roundRobinBtn)
-	case MULTICAST:
+	case engine.MULTICAST:
 		relayModeRadioGroup.SetActive(multicastBtn)
 	}
 
-	if g.net[id].paused {
+	if n.Paused {
 		*pauseBtnLabel = "resume"
 	} else {
 		*pauseBtnLabel = " pause "
@@ -483,11 +933,80 @@ func showNodeCtlWindow(g *Game, id nodeid) {
 	g.ui.AddWindow(nodeCtlWindow)
 }
 
-func promptPath(g *Game, handler func(string)) {
+func showEdgeCtlWindow(g *Game, e edge) {
+	g.selectedEdge = e
+
+	o, _ := selectedChan(g)
+	src, _ := g.net.Node(e.src)
+	dst, _ := g.net.Node(e.dst)
+
+	edgeTitleText.Label = fmt.Sprintf("Channel %s %v -> %s %v",
+		src.Name, src.ID, dst.Name, dst.ID)
+
+	updateEdgeStats(g)
+
+	bufSizeInput.SetText(strconv.Itoa(o.Link.BufSize))
+	delayInput.SetText(strconv.FormatInt(o.Link.Delay.Milliseconds(), 10))
+	jitterInput.SetText(strconv.FormatInt(o.Link.Jitter.Milliseconds(), 10))
+	lossInput.SetText(strconv.FormatFloat(o.Link.Loss, 'g', -1, 64))
+
+	edgeOverflowRadioGroup.SetActive(edgeOverflowBtns[o.Overflow])
+	edgeOverflowTimeoutInput.SetText(
+		strconv.FormatInt(o.Timeout.Milliseconds(), 10))
+
+	rw, rh := edgeCtlWindow.Contents.PreferredSize()
+	r := go_image.Rect(0, 0, rw, rh)
+	r = r.Add(go_image.Point{g.smx, g.smy})
+	edgeCtlWindow.SetLocation(r)
+
+	g.ui.AddWindow(edgeCtlWindow)
+}
+
+// Refreshes the statistics in the edge control panel. Called on every update
+// while the panel is open; closes it if the edge has been deleted.
+func updateEdgeStats(g *Game) {
+	o, ok := selectedChan(g)
+	if !ok {
+		edgeCtlWindow.Close()
+		return
+	}
+
+	edgeStatsText.Label = fmt.Sprintf(
+		"Messages: %d, buffer: %d/%d, usage: %.2f, dropped: %d, lost: %d",
+		o.Sent, o.Queued(), o.Link.BufSize, g.usage[g.selectedEdge],
+		o.Dropped, o.Lost)
+}
+
+// asks the user for a path, then calls handler with it; hint is shown above
+// the input, e.g. the supported formats
+func promptPath(g *Game, hint string, handler func(string)) {
 	pathSelectHandler = handler
+	pathSelectHint.Label = hint
 	g.ui.AddWindow(pathSelectWindow)
 }
 
+// Asks for a saved network and adds it to the current one, without stopping
+// the running nodes: the new nodes keep their IDs unless they are in use, are
+// centered on the mouse pointer and become the selection. Importing can be
+// undone like any other edit.
+func promptImport(g *Game) {
+	// the pointer moves to the dialog while the path is typed, the nodes go
+	// where it was before
+	x, y := g.wmx, g.wmy
+
+	promptPath(g, NETWORK_FORMATS, func(p string) {
+		f, err := os.Open(p)
+		if err != nil {
+			errPopUp(g, "Couldn't open file")
+			return
+		}
+
+		g.mergeNodes(f, engine.CodecFor(p), x, y, true)
+
+		f.Close()
+	})
+}
+
 func makeUI(g *Game) (ebitenui.UI, go_image.Rectangle) {
 	ui := ebitenui.UI{
 		Container: widget.NewContainer(),
@@ -496,6 +1015,8 @@ func makeUI(g *Game) (ebitenui.UI, go_image.Rectangle) {
 	toolbarWindow, toolbarRect := makeToolbarWindow(g)
 
 	makeNodeCtlWindow(g)
+	makeEdgeCtlWindow(g)
+	makeSelectionWindow(g)
 	makePathSelectWindow()
 	makeErrWindow()
 
@@ -506,10 +1027,4 @@ func makeUI(g *Game) (ebitenui.UI, go_image.Rectangle) {
 
 func makeImages() {
 	buttonImage = loadButtonImage()
-
-	nodeImage = ebiten.NewImage(nodeSize, nodeSize)
-	nodeImage.Fill(color.Black)
-
-	pausedNodeImage = ebiten.NewImage(nodeSize, nodeSize)
-	pausedNodeImage.Fill(color.Gray{Y: 150})
 }